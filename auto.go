@@ -0,0 +1,149 @@
+// auto.go
+//
+// Picking a Backend to suit the session.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "fmt"; "os"; "os/exec"; "strings" )
+
+// A Session is the kind of place menus can be shown: a Wayland or X
+// display, or just a terminal.
+//
+type Session string
+
+const(
+    SessionWayland Session = "wayland"
+    SessionX       Session = "x11"
+    SessionTTY     Session = "tty"
+)
+
+// DetectSession() tells what kind of Session this is: Wayland if
+// $WAYLAND_DISPLAY is set, X if $DISPLAY is, and otherwise a terminal.
+//
+func DetectSession() Session {
+    switch {
+        case os.Getenv("WAYLAND_DISPLAY") != "":
+            return SessionWayland
+        case os.Getenv("DISPLAY") != "":
+            return SessionX
+    }
+    return SessionTTY
+}
+
+// AutoBackends are the Backends BACKEND=auto tries (see Menu.Backend), in
+// order of preference, for each kind of Session. The first one that's
+// installed is used.
+//
+var AutoBackends = map[Session][]string{
+    SessionWayland: { "wofi", "fuzzel", "bemenu", "rofi", "dmenu", "tty" },
+    SessionX:       { "dmenu", "rofi", "bemenu", "tty" },
+    SessionTTY:     { "tty" },
+}
+
+// backendNeeds maps the Backends that need a display to the environment
+// variable that says there is one. (rofi and dmenu can run under XWayland,
+// which sets $DISPLAY.)
+//
+var backendNeeds = map[string]string{
+    "dmenu":  "DISPLAY",
+    "rofi":   "DISPLAY",
+    "wofi":   "WAYLAND_DISPLAY",
+    "fuzzel": "WAYLAND_DISPLAY",
+}
+
+// backendNames() returns the names of the Backends m.BackendName asks
+// for, in lower case: the ones in a comma-separated list, or the
+// AutoBackends for this Session if it's "auto" (or empty).
+//
+func (m *Menu) backendNames() []string {
+    name := strings.ToLower(strings.TrimSpace(m.BackendName))
+    if name == "" || name == "auto" {
+        return AutoBackends[DetectSession()]
+    }
+    names := make([]string, 0)
+    for _, n := range strings.Split(name, ",") {
+        if n = strings.TrimSpace(n); n != "" {
+            names = append(names, n)
+        }
+    }
+    return names
+}
+
+// findBackend() returns the path of the executable the named Backend would
+// run, and whether it can run at all in this session. Backends that don't
+// run an executable (like "tty", or ones a program has added to Backends)
+// have the path "".
+//
+func (m *Menu) findBackend(name string) (string, bool) {
+    if env, ok := backendNeeds[name]; ok && os.Getenv(env) == "" {
+        return "", false
+    }
+    return m.lookBackend(name)
+}
+
+// lookBackend() is like findBackend(), but only looks for the executable,
+// whether or not there's a display for it.
+//
+func (m *Menu) lookBackend(name string) (string, bool) {
+    switch name {
+        case "dmenu":
+            for _, p := range []string{ m.DmenuPath, "dmenu" } {
+                if p == "" {
+                    continue
+                }
+                if path, err := exec.LookPath(p); err == nil {
+                    return path, true
+                }
+            }
+            return "", false
+        case "rofi", "bemenu", "wofi", "fuzzel", "fzf":
+            path, err := exec.LookPath(name)
+            return path, err == nil
+    }
+    return "", true
+}
+
+// ChooseBackend() returns the Backend m's menus will be shown with, and
+// its name (see Backends), for diagnostics. If m.Backend is set, that's
+// the Backend, and the name is "".
+//
+// Otherwise, the names in m.BackendName are tried in order, skipping any
+// that need a display there isn't (dmenu and rofi need X; wofi and fuzzel
+// need Wayland) or whose executable can't be found; "auto" tries the
+// AutoBackends for this Session. If none of them will do and there's no
+// display at all, the menu is drawn on the terminal (see Term).
+//
+// This is done afresh for each menu, so it's not worth calling ahead of
+// time except to find out what it'll say.
+//
+func (m *Menu) ChooseBackend() (Backend, string, error) {
+    if m.Backend != nil {
+        return m.Backend, "", nil
+    }
+    names := m.backendNames()
+    for _, name := range names {
+        if _, ok := Backends[name]; !ok {
+            return nil, "", fmt.Errorf("dmx: unknown backend %q", name)
+        }
+        path, ok := m.findBackend(name)
+        if !ok {
+            continue
+        }
+        if name == "dmenu" {
+            return &Dmenu{ Path: path, IndexPatch: m.DmenuIndex }, name, nil
+        }
+        b, err := NewBackend(name, path)
+        return b, name, err
+    }
+    if DetectSession() == SessionTTY {
+        return &Term{}, "tty", nil
+    }
+    return nil, "", fmt.Errorf("dmx: none of the backends %s is available",
+                               strings.Join(names, ", "))
+}
+
+// ChooseBackend() is Menu.ChooseBackend() for the Default Menu.
+//
+func ChooseBackend() (Backend, string, error) { return Default.ChooseBackend() }
@@ -0,0 +1,616 @@
+// backend.go
+//
+// Menu programs that dmx knows how to drive.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bufio"; "bytes"; "context"; "fmt"; "io"; "os/exec"; "regexp"; "strconv"
+        "strings"; "sync"; "time"
+)
+
+// Style holds the configurable look of a menu and where it appears. Zero
+// values (empty strings, false, 0) mean "use the backend's own default".
+//
+// MaxLines caps the number of lines shown at once; if it is 0, the menu is
+// as tall as the number of items. Monitor and WindowID are passed to the
+// backend as they are (dmenu's -m and -w). LineHeight needs dmenu's
+// line-height patch.
+//
+type Style struct {
+    Font            string
+    NormalFG        string
+    NormalBG        string
+    SelectedFG      string
+    SelectedBG      string
+    Bottom          bool
+    CaseInsensitive bool
+    Monitor         string
+    WindowID        string
+    MaxLines        int
+    LineHeight      int
+}
+
+// Options holds everything a Backend needs to know in order to show a
+// menu: the prompt, how many lines to show, and the look of the thing.
+// Lines has already been limited by MaxLines. Not every Backend can honor
+// every option; see the documentation for each of them.
+//
+// If Index is set (which only happens if the Backend reports FeatIndex),
+// the Backend should print the index of the chosen line (counting from
+// zero) instead of the line itself, followed by a space and the text; if
+// the user typed something that isn't one of the lines, the index is -1.
+// The text may be left off after an index that isn't -1.
+//
+// Rows is nil unless the Backend has at least one of the FeatRows Features
+// and some of the Items have extras (see Iconer and friends); then it has
+// one RowInfo for each menu line, and the Backend shows what it can.
+//
+// If the Backend has FeatSelect, Selected is the index of the line that
+// should be highlighted when the menu appears. (Other Backends get the
+// default line moved to the top of the menu instead, and Selected is 0.)
+//
+// If Password is set (which only happens if the Backend reports
+// FeatPassword), there are no menu lines, and the Backend must not show
+// what the user types.
+//
+// If Multi is set (which only happens if the Backend reports FeatMulti),
+// the user may choose several lines, and the Backend should print each of
+// them (or its index) on a line of its own, in the order they were
+// chosen.
+//
+type Options struct {
+    Prompt   string
+    Lines    int
+    Index    bool
+    Rows     []RowInfo
+    Selected int
+    Password bool
+    Multi    bool
+    Style
+    table    *Table
+    dflt     Item
+    keyWidth int
+    treeMode TreeMode
+    treeSep  string
+}
+
+// A Backend is a program (or anything else) that can present a menu.
+//
+// Run() should read newline-terminated menu lines from input, let the user
+// pick one, and return it (newline-terminated, the way dmenu prints it). If
+// the user types something that isn't one of the lines, that text should be
+// returned instead. If the user dismisses the menu, Run() should return
+// ErrCancelled (along with any lines that were already chosen, if several
+// can be; see Options.Multi). If ctx finishes first, Run() should give up
+// and return ctx.Err().
+//
+type Backend interface {
+    Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error)
+}
+
+// A Feature is something beyond the basics that a Backend can do.
+//
+type Feature uint
+
+const(
+    // FeatIndex means the Backend can report the index of the chosen line
+    // (see Options.Index), so identical lines can be told apart.
+    FeatIndex Feature = 1 << iota
+    // FeatIcon, FeatUrgent, FeatActive and FeatSearchText mean the Backend
+    // can show the corresponding parts of a RowInfo (see Options.Rows).
+    FeatIcon
+    FeatUrgent
+    FeatActive
+    FeatSearchText
+    // FeatSelect means the Backend can start with a line other than the
+    // first one highlighted (see Options.Selected).
+    FeatSelect
+    // FeatPassword means the Backend can hide what the user types (see
+    // Options.Password).
+    FeatPassword
+    // FeatMulti means the Backend can let the user choose several lines
+    // (see Options.Multi).
+    FeatMulti
+)
+
+// A Featurer is a Backend that can do more than the basics. Backends that
+// don't implement it are assumed to have no Features.
+//
+type Featurer interface {
+    Features() Feature
+}
+
+// Supports() reports whether b has all of the given Features.
+//
+func Supports(b Backend, f Feature) bool {
+    if fb, ok := b.(Featurer); ok {
+        return fb.Features() & f == f
+    }
+    return false
+}
+
+// Dmenu drives suckless dmenu. If Path is empty, "dmenu" is looked for in
+// $PATH.
+//
+// Set IndexPatch if your dmenu has the "printindex" patch (the -ix option).
+// That dmenu prints -1 (and not the text) when the user types something
+// that doesn't match, so typed text can't be recovered with it.
+//
+// Whether dmenu has the "password" patch (the -P option) is found out by
+// looking for -P in the usage message it prints, which takes running it,
+// so it isn't one of its Features(); see HasPassword(). Any dmenu can
+// choose several lines: Ctrl-Enter prints the highlighted one and keeps
+// the menu open.
+//
+type Dmenu struct {
+    Path       string
+    IndexPatch bool
+}
+
+func (b *Dmenu) Features() Feature {
+    f := FeatMulti
+    if b.IndexPatch {
+        f |= FeatIndex
+    }
+    return f
+}
+
+// HasPassword() reports whether this dmenu has the password patch, and so
+// can be used by Password(). The first time it's asked about an
+// executable, it has to run it to find out.
+//
+func (b *Dmenu) HasPassword() bool {
+    return dmenuHasPassword(orDefault(b.Path, "dmenu"))
+}
+
+// dmenuPasswords remembers which dmenu executables have the password
+// patch, so each one only has to be asked once.
+//
+var dmenuPasswords sync.Map
+
+// dmenuFlagsRe matches the single-letter flags in dmenu's usage message,
+// like "[-bfiv]".
+//
+var dmenuFlagsRe = regexp.MustCompile(`\[-([[:alpha:]]+)\]`)
+
+// dmenuHasPassword() reports whether the dmenu at path has the password
+// patch. dmenu prints its usage message when given an option it doesn't
+// know, which the patched one lists -P in.
+//
+func dmenuHasPassword(path string) bool {
+    if has, ok := dmenuPasswords.Load(path); ok {
+        return has.(bool)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    out, _ := exec.CommandContext(ctx, path, "-h").CombinedOutput()
+    has := false
+    if m := dmenuFlagsRe.FindSubmatch(out); m != nil {
+        has = bytes.IndexByte(m[1], 'P') >= 0
+    }
+    dmenuPasswords.Store(path, has)
+    return has
+}
+
+func (b *Dmenu) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    path := orDefault(b.Path, "dmenu")
+    args := []string{ "-l", fmt.Sprintf("%d", opts.Lines), "-p", opts.Prompt }
+    if opts.Index && b.IndexPatch {
+        args = append(args, "-ix")
+    }
+    if opts.Password {
+        args = append(args, "-P")
+    }
+    args = appendNonEmpty(args, "-fn", opts.Font)
+    args = appendNonEmpty(args, "-nb", opts.NormalBG)
+    args = appendNonEmpty(args, "-nf", opts.NormalFG)
+    args = appendNonEmpty(args, "-sb", opts.SelectedBG)
+    args = appendNonEmpty(args, "-sf", opts.SelectedFG)
+    args = appendNonEmpty(args, "-m",  opts.Monitor)
+    args = appendNonEmpty(args, "-w",  opts.WindowID)
+    if opts.LineHeight > 0 {
+        args = append(args, "-h", fmt.Sprintf("%d", opts.LineHeight))
+    }
+    if opts.Bottom {
+        args = append(args, "-b")
+    }
+    if opts.CaseInsensitive {
+        args = append(args, "-i")
+    }
+    return runExec(ctx, path, args, input)
+}
+
+// Rofi drives rofi in its dmenu mode. If Path is empty, "rofi" is looked
+// for in $PATH. Colors are passed as a -theme-str; the font should be
+// something Pango understands (like "Mono 10"), not an XLFD. LineHeight
+// is ignored. Rofi can show all of an Item's extras (see Options.Rows).
+// When several lines can be chosen, Shift-Enter marks them.
+//
+type Rofi struct {
+    Path string
+}
+
+func (b *Rofi) Features() Feature {
+    return FeatIndex | FeatRows | FeatSelect | FeatPassword | FeatMulti
+}
+
+func (b *Rofi) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    args := []string{ "-dmenu", "-l", fmt.Sprintf("%d", opts.Lines),
+                      "-p", opts.Prompt }
+    if opts.Index {
+        args = append(args, "-format", "i s")
+    }
+    if opts.Selected > 0 {
+        args = append(args, "-selected-row", strconv.Itoa(opts.Selected))
+    }
+    if opts.Password {
+        args = append(args, "-password")
+    }
+    if opts.Multi {
+        args = append(args, "-multi-select")
+    }
+    args = appendNonEmpty(args, "-font", opts.Font)
+    args = appendNonEmpty(args, "-m", opts.Monitor)
+    args = appendNonEmpty(args, "-w", opts.WindowID)
+    if opts.CaseInsensitive {
+        args = append(args, "-i")
+    }
+    theme := make([]string, 0, 4)
+    if opts.NormalBG != "" {
+        theme = append(theme, fmt.Sprintf("window { background-color: %s; }",
+                                          opts.NormalBG))
+    }
+    if opts.Bottom {
+        theme = append(theme, "window { location: south; anchor: south; }")
+    }
+    if opts.NormalBG != "" || opts.NormalFG != "" {
+        theme = append(theme, "element normal.normal {" +
+                              themeColors(opts.NormalBG, opts.NormalFG) + "}")
+    }
+    if opts.SelectedBG != "" || opts.SelectedFG != "" {
+        theme = append(theme, "element selected.normal {" +
+                              themeColors(opts.SelectedBG, opts.SelectedFG) + "}")
+    }
+    if len(theme) > 0 {
+        args = append(args, "-theme-str", strings.Join(theme, " "))
+    }
+    if opts.Rows != nil {
+        var extra []string
+        var err error
+        input, extra, err = rofiRows(input, opts.Rows)
+        if err != nil {
+            return nil, err
+        }
+        args = append(args, extra...)
+    }
+    return runExec(ctx, orDefault(b.Path, "rofi"), args, input)
+}
+
+// rofiRows() adds rofi's row options (an icon and hidden search text) to
+// the menu lines read from input, and returns them along with the
+// arguments that mark the urgent and active rows.
+//
+func rofiRows(input io.Reader, rows []RowInfo) (io.Reader, []string, error) {
+    var out bytes.Buffer
+    var urgent, active []string
+    show_icons := false
+    rdr := bufio.NewReader(input)
+    for n := 0; ; n++ {
+        line, err := rdr.ReadBytes('\n')
+        if len(line) == 0 && err == io.EOF {
+            break
+        } else if err != nil && err != io.EOF {
+            return nil, nil, err
+        }
+        if n >= len(rows) || rows[n] == (RowInfo{}) {
+            out.Write(line)
+            continue
+        }
+        ri := rows[n]
+        line = bytes.TrimSuffix(line, CRLF)
+        props := make([]string, 0, 4)
+        if ri.Icon != "" {
+            props = append(props, "icon", rofiClean(ri.Icon))
+            show_icons = true
+        }
+        if ri.SearchText != "" {
+            props = append(props, "meta", rofiClean(ri.SearchText))
+        }
+        out.Write(line)
+        if len(props) > 0 {
+            out.WriteString("\x00" + strings.Join(props, "\x1f"))
+        }
+        out.Write(CRLF)
+        if ri.Urgent {
+            urgent = append(urgent, strconv.Itoa(n))
+        }
+        if ri.Active {
+            active = append(active, strconv.Itoa(n))
+        }
+    }
+    
+    args := make([]string, 0, 5)
+    if show_icons {
+        args = append(args, "-show-icons")
+    }
+    if len(urgent) > 0 {
+        args = append(args, "-u", strings.Join(urgent, ","))
+    }
+    if len(active) > 0 {
+        args = append(args, "-a", strings.Join(active, ","))
+    }
+    return &out, args, nil
+}
+
+// rofiClean() removes the characters that would break up rofi's row
+// options.
+//
+func rofiClean(s string) string {
+    return strings.Map(func(r rune) rune {
+        switch r {
+            case 0, 0x1f, '\n':
+                return -1
+        }
+        return r
+    }, s)
+}
+
+// Bemenu drives bemenu, which works on both X and Wayland. If Path is
+// empty, "bemenu" is looked for in $PATH. Like rofi, it wants a Pango font.
+// WindowID is ignored. Passwords are hidden with -x.
+//
+type Bemenu struct {
+    Path string
+}
+
+func (b *Bemenu) Features() Feature { return FeatPassword }
+
+func (b *Bemenu) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    args := []string{ "-l", fmt.Sprintf("%d", opts.Lines), "-p", opts.Prompt }
+    args = appendNonEmpty(args, "--fn", opts.Font)
+    args = appendNonEmpty(args, "--nb", opts.NormalBG)
+    args = appendNonEmpty(args, "--nf", opts.NormalFG)
+    args = appendNonEmpty(args, "--sb", opts.SelectedBG)
+    args = appendNonEmpty(args, "--sf", opts.SelectedFG)
+    args = appendNonEmpty(args, "-m",   opts.Monitor)
+    if opts.LineHeight > 0 {
+        args = append(args, "-H", fmt.Sprintf("%d", opts.LineHeight))
+    }
+    if opts.Bottom {
+        args = append(args, "-b")
+    }
+    if opts.CaseInsensitive {
+        args = append(args, "-i")
+    }
+    if opts.Password {
+        args = append(args, "-x")
+    }
+    return runExec(ctx, orDefault(b.Path, "bemenu"), args, input)
+}
+
+// Wofi drives wofi in its dmenu mode. If Path is empty, "wofi" is looked
+// for in $PATH. wofi takes its font and colors from its own CSS file, so
+// only the prompt, line count, position, case-sensitivity and password
+// mode are passed along.
+//
+type Wofi struct {
+    Path string
+}
+
+func (b *Wofi) Features() Feature { return FeatPassword }
+
+func (b *Wofi) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    args := []string{ "--dmenu", "--lines", fmt.Sprintf("%d", opts.Lines),
+                      "--prompt", opts.Prompt }
+    args = appendNonEmpty(args, "--monitor", opts.Monitor)
+    if opts.Password {
+        args = append(args, "--password")
+    }
+    if opts.Bottom {
+        args = append(args, "--location", "bottom")
+    }
+    if opts.CaseInsensitive {
+        args = append(args, "--insensitive")
+    }
+    return runExec(ctx, orDefault(b.Path, "wofi"), args, input)
+}
+
+// Fuzzel drives fuzzel in its dmenu mode, on Wayland. If Path is empty,
+// "fuzzel" is looked for in $PATH. It wants a fontconfig font (like
+// "Mono:size=10"), and only understands colors given as #rgb or #rrggbb;
+// LineHeight is passed on in points. WindowID is ignored, and fuzzel
+// always matches without regard to case.
+//
+type Fuzzel struct {
+    Path string
+}
+
+func (b *Fuzzel) Features() Feature { return FeatPassword }
+
+func (b *Fuzzel) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    args := []string{ "--dmenu", "--lines", fmt.Sprintf("%d", opts.Lines),
+                      "--prompt", opts.Prompt }
+    args = appendNonEmpty(args, "--font", opts.Font)
+    args = appendNonEmpty(args, "--output", opts.Monitor)
+    for _, c := range [][2]string{ { "--background", opts.NormalBG },
+                                   { "--text-color", opts.NormalFG },
+                                   { "--selection-color", opts.SelectedBG },
+                                   { "--selection-text-color", opts.SelectedFG } } {
+        if rgb, ok := hexColor(c[1]); ok {
+            args = append(args, c[0], fmt.Sprintf("%06xff", rgb))
+        }
+    }
+    if opts.LineHeight > 0 {
+        args = append(args, "--line-height", fmt.Sprintf("%d", opts.LineHeight))
+    }
+    if opts.Password {
+        args = append(args, "--password")
+    }
+    if opts.Bottom {
+        args = append(args, "--anchor", "bottom")
+    }
+    return runExec(ctx, orDefault(b.Path, "fuzzel"), args, input)
+}
+
+// Fzf drives fzf, for use in a terminal. If Path is empty, "fzf" is looked
+// for in $PATH. fzf draws on the terminal, so the font, monitor, window
+// and line height are ignored; the colors are passed with --color. Bottom
+// is fzf's usual layout; otherwise the list is drawn from the top down.
+// When several lines can be chosen, Tab marks them.
+//
+type Fzf struct {
+    Path string
+}
+
+func (b *Fzf) Features() Feature { return FeatMulti }
+
+func (b *Fzf) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    args := []string{ "--print-query", "--prompt", opts.Prompt }
+    if !opts.Bottom {
+        args = append(args, "--layout", "reverse")
+    }
+    if opts.CaseInsensitive {
+        args = append(args, "-i")
+    }
+    if opts.Multi {
+        args = append(args, "--multi")
+    }
+    if opts.Lines > 0 {
+        // fzf's --height counts its prompt and info lines, too.
+        args = append(args, "--height", fmt.Sprintf("%d", opts.Lines + 2))
+    }
+    colors := make([]string, 0, 4)
+    for _, c := range [][2]string{ { "fg", opts.NormalFG }, { "bg", opts.NormalBG },
+                                   { "fg+", opts.SelectedFG }, { "bg+", opts.SelectedBG } } {
+        if c[1] != "" {
+            colors = append(colors, c[0] + ":" + c[1])
+        }
+    }
+    if len(colors) > 0 {
+        args = append(args, "--color", strings.Join(colors, ","))
+    }
+
+    // With --print-query, fzf prints the query on the first line and the
+    // selection (if there is one) on the second. It exits with status 1
+    // if nothing matched and 130 if the user hit Escape.
+    out, code, err := execCmd(ctx, orDefault(b.Path, "fzf"), args, input)
+    if code == 130 {
+        return nil, ErrCancelled
+    } else if err != nil && code != 1 {
+        return nil, err
+    }
+    query, sel, _ := bytes.Cut(out, CRLF)
+    if len(sel) == 0 {
+        if len(query) == 0 {
+            return nil, ErrCancelled
+        }
+        return ensureReturnminated(query), nil
+    }
+    return sel, nil
+}
+
+// Backends maps the names accepted by the "backend" configuration option
+// to functions that make the corresponding Backend. The argument is the
+// path to the executable, which may be empty ("tty", which is built in,
+// ignores it).
+//
+var Backends = map[string]func(string) Backend{
+    "dmenu":  func(p string) Backend { return &Dmenu{ Path: p } },
+    "rofi":   func(p string) Backend { return &Rofi{ Path: p } },
+    "bemenu": func(p string) Backend { return &Bemenu{ Path: p } },
+    "wofi":   func(p string) Backend { return &Wofi{ Path: p } },
+    "fuzzel": func(p string) Backend { return &Fuzzel{ Path: p } },
+    "fzf":    func(p string) Backend { return &Fzf{ Path: p } },
+    "tty":    func(string) Backend { return &Term{} },
+}
+
+// NewBackend() returns the named Backend (see Backends), which will run
+// the executable at path, or its default location if path is empty.
+//
+func NewBackend(name, path string) (Backend, error) {
+    mk, ok := Backends[strings.ToLower(name)]
+    if !ok {
+        return nil, fmt.Errorf("dmx: unknown backend %q", name)
+    }
+    return mk(path), nil
+}
+
+// runExec() runs the executable at path with the given arguments, feeding
+// it input and returning whatever it prints. If the program exits with
+// status 1 (which is how dmenu and friends say the user dismissed them),
+// the error is ErrCancelled, and the output is whatever was printed before
+// that (which is only anything if lines were chosen with dmenu's
+// Ctrl-Enter, or the like).
+//
+func runExec(ctx context.Context, path string, args []string, input io.Reader) ([]byte, error) {
+    out, code, err := execCmd(ctx, path, args, input)
+    if code == 1 {
+        return out, ErrCancelled
+    }
+    return out, err
+}
+
+// execCmd() does the work for runExec(), but also returns the program's
+// exit status (or -1 if it didn't exit normally) and doesn't interpret it.
+// If the program was killed because ctx finished, the error is ctx.Err()
+// rather than the (uninformative) "signal: killed" from the exec package.
+//
+func execCmd(ctx context.Context, path string, args []string, input io.Reader) ([]byte, int, error) {
+    cmd := exec.CommandContext(ctx, path, args...)
+    cmd.Stdin = input
+    var output bytes.Buffer
+    cmd.Stdout = &output
+    err := cmd.Run()
+    if err != nil && ctx.Err() != nil {
+        return nil, -1, ctx.Err()
+    }
+    if x, is_exit := err.(*exec.ExitError); is_exit {
+        return output.Bytes(), x.ExitCode(), err
+    }
+    return output.Bytes(), 0, err
+}
+
+func orDefault(s, dflt string) string {
+    if s == "" {
+        return dflt
+    }
+    return s
+}
+
+func appendNonEmpty(args []string, flag, val string) []string {
+    if val == "" {
+        return args
+    }
+    return append(args, flag, val)
+}
+
+func themeColors(bg, fg string) string {
+    s := ""
+    if bg != "" {
+        s += fmt.Sprintf(" background-color: %s;", bg)
+    }
+    if fg != "" {
+        s += fmt.Sprintf(" text-color: %s;", fg)
+    }
+    return s + " "
+}
+
+// hexColor() returns the value of a #rgb or #rrggbb color as 0xrrggbb,
+// and whether it was one of those.
+//
+func hexColor(color string) (uint32, bool) {
+    hex, ok := strings.CutPrefix(color, "#")
+    if !ok {
+        return 0, false
+    }
+    if len(hex) == 3 {
+        hex = string([]byte{ hex[0], hex[0], hex[1], hex[1], hex[2], hex[2] })
+    }
+    if len(hex) != 6 {
+        return 0, false
+    }
+    rgb, err := strconv.ParseUint(hex, 16, 32)
+    return uint32(rgb), err == nil
+}
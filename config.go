@@ -0,0 +1,332 @@
+// config.go
+//
+// Reading dmx.conf.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bufio"; "fmt"; "os"; "path/filepath"; "sort"; "strconv"; "strings" )
+
+// menuKeys are the configuration keys that set a Menu's options (see
+// Menu.set()), in the order they're documented in dmx.conf.
+//
+var menuKeys = []string{
+    "backend", "dmenu", "dmenu_index",
+    "font", "normal_bg", "normal_fg", "selected_bg", "selected_fg",
+    "bottom", "case_insensitive", "monitor", "lines", "line_height",
+}
+
+// ConfigPaths() returns the places Autoconfigure() looks for a
+// configuration file, in order:
+//
+//   - the paths in other_cfgs (which can be nil)
+//   - $DMX_CONFIG, if it's set
+//   - $XDG_CONFIG_HOME/dmx.conf ($XDG_CONFIG_HOME defaults to ~/.config)
+//   - dmx.conf in each of the directories in $XDG_CONFIG_DIRS (which
+//     defaults to /etc/xdg)
+//   - /usr/share/dmx.conf
+//
+func ConfigPaths(other_cfgs []string) []string {
+    cfg_files := make([]string, 0, len(other_cfgs)+4)
+    for _, fname := range other_cfgs {
+        cfg_files = append(cfg_files, fname)
+    }
+    if env := os.Getenv("DMX_CONFIG"); env != "" {
+        cfg_files = append(cfg_files, env)
+    }
+    
+    cfg_home := os.Getenv("XDG_CONFIG_HOME")
+    if cfg_home == "" {
+        cfg_home = os.ExpandEnv("$HOME/.config")
+    }
+    cfg_files = append(cfg_files, filepath.Join(cfg_home, "dmx.conf"))
+    
+    cfg_dirs := os.Getenv("XDG_CONFIG_DIRS")
+    if cfg_dirs == "" {
+        cfg_dirs = "/etc/xdg"
+    }
+    for _, dir := range filepath.SplitList(cfg_dirs) {
+        if dir != "" {
+            cfg_files = append(cfg_files, filepath.Join(dir, "dmx.conf"))
+        }
+    }
+    
+    cfg_files = append(cfg_files, "/usr/share/dmx.conf")
+    return cfg_files
+}
+
+// Autoconfigure() reads and responds to a configuration file. It tries the
+// paths returned by ConfigPaths(other_cfgs), and stops and reads from the
+// first one it finds. Finding none of them is not an error; the Menu just
+// keeps the settings it has.
+//
+// The options at the top of the file (before any [section]) apply first.
+// Then, if m.Theme is set, or the file has a "theme" option, the options
+// in the [theme name] section with that name are applied on top of them.
+//
+// After that, any option can be overridden by an environment variable
+// named DMX_ and the option's key in upper case, like DMX_FONT or
+// DMX_NORMAL_BG, so a one-off invocation can be restyled without
+// editing any files. ($DMX_THEME picks the theme, unless m.Theme is set.)
+//
+func (m *Menu) Autoconfigure(other_cfgs []string) error {
+    return m.configure(other_cfgs, "")
+}
+
+// configure() does the work for Autoconfigure(). If tool isn't "", the
+// options in the [tool] section are applied after the ones at the top of
+// the file (and the tool section's "theme" option, if any, wins).
+//
+func (m *Menu) configure(other_cfgs []string, tool string) error {
+    var first_err error
+    note := func(err error) {
+        if err != nil && first_err == nil {
+            first_err = err
+        }
+    }
+    
+    m.conf = nil
+    for _, fname := range ConfigPaths(other_cfgs) {
+        cf, err := readConfig(fname)
+        if os.IsNotExist(err) {
+            continue
+        } else if err != nil {
+            note(err)
+            break
+        }
+        m.conf = cf
+        note(m.setSection(""))
+        if tool != "" {
+            note(m.setSection(sectionName(tool)))
+        }
+        break
+    }
+    
+    theme := m.Theme
+    if theme == "" {
+        theme = os.Getenv("DMX_THEME")
+    }
+    if theme == "" && m.conf != nil && tool != "" {
+        theme = m.conf.section(sectionName(tool))["theme"].val
+    }
+    if theme == "" && m.conf != nil {
+        theme = m.conf.section("")["theme"].val
+    }
+    if theme != "" {
+        note(m.ApplyTheme(theme))
+    }
+    
+    for _, key := range menuKeys {
+        env := "DMX_" + strings.ToUpper(key)
+        if val, ok := os.LookupEnv(env); ok {
+            if _, err := m.set(key, strings.TrimSpace(val)); err != nil {
+                note(fmt.Errorf("$%s: %w", env, err))
+            }
+        }
+    }
+    return first_err
+}
+
+// setSection() applies the Menu options in the named section of the
+// configuration file m was last configured from.
+//
+func (m *Menu) setSection(name string) error {
+    var first_err error
+    for key, cv := range m.conf.section(name) {
+        if _, err := m.set(key, cv.val); err != nil && first_err == nil {
+            first_err = fmt.Errorf("%s:%d: %w", m.conf.path, cv.line, err)
+        }
+    }
+    return first_err
+}
+
+// ApplyTheme() applies the options in the [theme name] section of the
+// configuration file m was last configured from. A theme only changes the
+// options it mentions. It is an error if there is no such theme.
+//
+func (m *Menu) ApplyTheme(name string) error {
+    sect := sectionName("theme " + name)
+    if m.conf == nil {
+        return fmt.Errorf("dmx: no theme %q (no configuration file)", name)
+    }
+    if _, ok := m.conf.sections[sect]; !ok {
+        return fmt.Errorf("dmx: no theme %q in %s", name, m.conf.path)
+    }
+    m.Theme = name
+    return m.setSection(sect)
+}
+
+// Themes() returns the names of the themes in the configuration file m
+// was last configured from.
+//
+func (m *Menu) Themes() []string {
+    names := make([]string, 0)
+    if m.conf == nil {
+        return names
+    }
+    for sect := range m.conf.sections {
+        if name, ok := strings.CutPrefix(sect, "theme "); ok {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+// HasTheme() reports whether the configuration file m was last configured
+// from has the named theme.
+//
+func (m *Menu) HasTheme(name string) bool {
+    if m.conf == nil {
+        return false
+    }
+    _, ok := m.conf.sections[sectionName("theme " + name)]
+    return ok
+}
+
+// ApplyTheme() applies a theme to the Default Menu.
+//
+func ApplyTheme(name string) error { return Default.ApplyTheme(name) }
+
+// ConfigFile() returns the path of the configuration file the last call
+// to Autoconfigure() read, or "" if it didn't find one.
+//
+func (m *Menu) ConfigFile() string {
+    if m.conf == nil {
+        return ""
+    }
+    return m.conf.path
+}
+
+// ConfigFile() returns the configuration file the Default Menu was
+// configured from.
+//
+func ConfigFile() string { return Default.ConfigFile() }
+
+// set() sets the option named by the (lower-case) configuration key. It
+// returns false if key isn't one of the Menu's options, and an error if
+// val isn't a legal value for it.
+//
+func (m *Menu) set(key, val string) (bool, error) {
+    var err error
+    switch key {
+        case "backend":          m.BackendName = val
+        case "dmenu":            m.DmenuPath = val
+        case "dmenu_index":      m.DmenuIndex, err = parseBool(val)
+        case "font":             m.Font = val
+        case "normal_bg":        m.NormalBG = val
+        case "normal_fg":        m.NormalFG = val
+        case "selected_bg":      m.SelectedBG = val
+        case "selected_fg":      m.SelectedFG = val
+        case "monitor":          m.Monitor = val
+        case "bottom":           m.Bottom, err = parseBool(val)
+        case "case_insensitive": m.CaseInsensitive, err = parseBool(val)
+        case "lines":            m.MaxLines, err = strconv.Atoi(val)
+        case "line_height":      m.LineHeight, err = strconv.Atoi(val)
+        default:
+            return false, nil
+    }
+    if err != nil {
+        return true, fmt.Errorf("bad value %q for %s", val, strings.ToUpper(key))
+    }
+    return true, nil
+}
+
+// parseBool() is strconv.ParseBool(), but also accepts yes/no and on/off,
+// which read more naturally in a configuration file.
+//
+func parseBool(val string) (bool, error) {
+    switch strings.ToLower(val) {
+        case "yes", "on":
+            return true, nil
+        case "no", "off":
+            return false, nil
+    }
+    return strconv.ParseBool(val)
+}
+
+// A confValue is a single value from a configuration file, along with the
+// line it came from.
+//
+type confValue struct {
+    val  string
+    line int
+}
+
+// A confFile is a parsed configuration file. Keys before the first
+// [section] header are in the global section, whose name is "".
+//
+type confFile struct {
+    path     string
+    sections map[string]map[string]confValue
+    headers  map[string]int         // the line each section started on
+}
+
+// section() returns the keys and values in the named section (which is
+// empty if the file doesn't have one).
+//
+func (cf *confFile) section(name string) map[string]confValue {
+    return cf.sections[name]
+}
+
+// hasSection() reports whether the file has the named section.
+//
+func (cf *confFile) hasSection(name string) bool {
+    _, ok := cf.sections[sectionName(name)]
+    return ok
+}
+
+// sectionName() normalizes a [section] header: lower case, with runs of
+// whitespace squeezed down to one space.
+//
+func sectionName(hdr string) string {
+    return strings.ToLower(strings.Join(strings.Fields(hdr), " "))
+}
+
+// readConfig() reads a configuration file made of KEY=value lines, which
+// may be divided into sections by [section name] headers. Blank lines,
+// lines starting with # and lines without an = are ignored. Keys are
+// case-insensitive (and are stored in lower case); whitespace around keys
+// and values is stripped.
+//
+func readConfig(fname string) (*confFile, error) {
+    f, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    
+    cf := &confFile{
+        path:     fname,
+        sections: map[string]map[string]confValue{ "": {} },
+        headers:  map[string]int{ "": 0 },
+    }
+    cur := cf.sections[""]
+    scnr := bufio.NewScanner(f)
+    line_n := 0
+    for scnr.Scan() {
+        line_n++
+        line := strings.TrimSpace(scnr.Text())
+        if line == "" || line[0] == '#' {
+            continue
+        }
+        if line[0] == '[' && line[len(line)-1] == ']' {
+            name := sectionName(line[1:len(line)-1])
+            if _, seen := cf.sections[name]; !seen {
+                cf.sections[name] = make(map[string]confValue)
+                cf.headers[name] = line_n
+            }
+            cur = cf.sections[name]
+            continue
+        }
+        key, val, found := strings.Cut(line, "=")
+        if !found {
+            continue
+        }
+        cur[strings.ToLower(strings.TrimSpace(key))] = confValue{
+            val: strings.TrimSpace(val), line: line_n,
+        }
+    }
+    return cf, scnr.Err()
+}
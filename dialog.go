@@ -0,0 +1,153 @@
+// dialog.go
+//
+// Menus that ask for text (or a password), or for a yes or no, instead of
+// a choice.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bytes"; "context" )
+
+// Prompt() asks the user to type something. See the package-level
+// Prompt().
+//
+func (m *Menu) Prompt(prompt, dflt string, opts ...Option) (string, error) {
+    return m.PromptContext(context.Background(), prompt, dflt, opts...)
+}
+
+// PromptContext() is like Prompt(), but gives up when ctx finishes.
+//
+func (m *Menu) PromptContext(ctx context.Context, prompt, dflt string, opts ...Option) (string, error) {
+    var lines [][]byte
+    if dflt != "" {
+        lines = [][]byte{ []byte(dflt) }
+    }
+    out, err := m.RunContext(ctx, prompt, lines, opts...)
+    if err != nil {
+        return "", err
+    }
+    return string(bytes.TrimSuffix(out, CRLF)), nil
+}
+
+// Confirm() asks the user a yes-or-no question. See the package-level
+// Confirm().
+//
+func (m *Menu) Confirm(question string, opts ...Option) (bool, error) {
+    return m.ConfirmContext(context.Background(), question, opts...)
+}
+
+// ConfirmContext() is like Confirm(), but gives up when ctx finishes.
+//
+func (m *Menu) ConfirmContext(ctx context.Context, question string, opts ...Option) (bool, error) {
+    yes, no := confirmAnswer("Yes"), confirmAnswer("No")
+    opts = append(opts[:len(opts):len(opts)], WithDefault(no))
+    res, err := m.ChooseContext(ctx, question, ItemList{ yes, no }, opts...)
+    if err != nil {
+        return false, err
+    }
+    return res.Item == Item(yes), nil
+}
+
+// Password() asks the user for a password. See the package-level
+// Password().
+//
+func (m *Menu) Password(prompt string, opts ...Option) (string, error) {
+    return m.PasswordContext(context.Background(), prompt, opts...)
+}
+
+// PasswordContext() is like Password(), but gives up when ctx finishes.
+//
+func (m *Menu) PasswordContext(ctx context.Context, prompt string, opts ...Option) (string, error) {
+    b, err := m.backend()
+    if err != nil {
+        return "", err
+    }
+    if !canHide(b) {
+        return "", ErrNoPassword
+    }
+    o := m.options(prompt, 0, opts)
+    o.Password = true
+    out, err := b.Run(ctx, o, bytes.NewReader(nil))
+    if err != nil {
+        return "", err
+    }
+    return string(bytes.TrimSuffix(out, CRLF)), nil
+}
+
+// A passwordProber is a Backend that can't tell whether it can hide what's
+// typed without doing something too slow to do for every menu (like
+// running dmenu to see if it has the password patch), so it's only asked
+// when a password is wanted.
+//
+type passwordProber interface {
+    HasPassword() bool
+}
+
+// canHide() reports whether b can be used to ask for a password.
+//
+func canHide(b Backend) bool {
+    if pp, ok := b.(passwordProber); ok {
+        return pp.HasPassword()
+    }
+    return Supports(b, FeatPassword)
+}
+
+// A confirmAnswer is one of the lines of a Confirm() menu.
+//
+type confirmAnswer string
+
+func (ca confirmAnswer) Key() string { return string(ca) }
+func (ca confirmAnswer) MenuLine(int) []byte { return []byte(ca) }
+func (ca confirmAnswer) SortsBefore(Item) bool { return false }
+
+// Prompt() shows a menu with no lines, or just dflt if it isn't "", and
+// returns whatever the user types (without the trailing newline). Choosing
+// dflt returns it; to enter text that dflt starts with, use dmenu's
+// Shift-Enter (or the equivalent in other backends). If the user dismisses
+// the menu, the error is ErrCancelled.
+//
+func Prompt(prompt, dflt string, opts ...Option) (string, error) {
+    return Default.Prompt(prompt, dflt, opts...)
+}
+
+// PromptContext() is to Prompt() as DmenuSelectContext() is to
+// DmenuSelect().
+//
+func PromptContext(ctx context.Context, prompt, dflt string, opts ...Option) (string, error) {
+    return Default.PromptContext(ctx, prompt, dflt, opts...)
+}
+
+// Confirm() asks a question with a menu of Yes and No (with No
+// highlighted), and reports whether the user chose Yes. Dismissing the
+// menu, or typing anything else, counts as No rather than as an error.
+//
+func Confirm(question string, opts ...Option) (bool, error) {
+    return Default.Confirm(question, opts...)
+}
+
+// ConfirmContext() is to Confirm() as DmenuSelectContext() is to
+// DmenuSelect().
+//
+func ConfirmContext(ctx context.Context, question string, opts ...Option) (bool, error) {
+    return Default.ConfirmContext(ctx, question, opts...)
+}
+
+// Password() asks for a password (or anything else that shouldn't be
+// seen): it shows an empty menu that doesn't show what's typed, and
+// returns what was. That only works with a Backend that reports
+// FeatPassword: rofi, bemenu, wofi, fuzzel, the terminal menu, or a dmenu
+// with the password patch. With any other Backend, nothing is shown, and
+// the error is ErrNoPassword. If the user dismisses the menu, the error is
+// ErrCancelled.
+//
+func Password(prompt string, opts ...Option) (string, error) {
+    return Default.Password(prompt, opts...)
+}
+
+// PasswordContext() is to Password() as DmenuSelectContext() is to
+// DmenuSelect().
+//
+func PasswordContext(ctx context.Context, prompt string, opts ...Option) (string, error) {
+    return Default.PasswordContext(ctx, prompt, opts...)
+}
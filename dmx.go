@@ -1,6 +1,7 @@
 // dmx.go
 //
-// A package for interacting with suckless tools' dmenu.
+// A package for interacting with suckless tools' dmenu (and, through the
+// Backend interface, similar programs like rofi, bemenu, wofi and fzf).
 //
 // https://github.com/d2718/dmx
 //
@@ -11,50 +12,36 @@
 // suckless: http://suckless.org/
 // dmenu:    http://tools.suckless.org/dmenu/
 //
-// This package depends on my dconfig package:
-// https://github.com/d2718/dconfig
-//
 package dmx
 
-import( "bytes"; "fmt"; "os"; "os/exec";
-        "github.com/d2718/dconfig"
-)
+import( "bytes"; "context"; "errors" )
 
 var(
-    DmenuPath string = "/usr/local/bin/dmenu"
-    Font string =  "-*-fixed-medium-r-normal--13-*-*-*-*-*-ISO10646-*" // "ProggyCleanTTCE-12"
-    NormalFG   string = "#000"
-    NormalBG   string = "#444"
-    SelectedFG string = "#ddd"
-    SelectedBG string = "#222"
     CRLF []byte
     crlfLength int
 )
 
-// Autoconfigure() reads and responds to a configuration file.
-// It first tries all the paths in the supplied slice of strings (which can
-// be nil), then in ~/.config/dmx.conf, then in /usr/share/dmx.conf. It stops
-// and reads from the first one it finds.
+// ErrCancelled is returned when the user dismisses the menu (for example,
+// by hitting Escape) instead of choosing something. dmenu signals this by
+// exiting with status 1.
+//
+var ErrCancelled = errors.New("dmx: menu cancelled")
+
+// ErrNoPassword is returned by Password() when the Backend can't hide
+// what's typed into it (see FeatPassword).
+//
+var ErrNoPassword = errors.New("dmx: backend can't hide a password")
+
+// ErrAmbiguous is returned when the menu lines can't be made distinct
+// enough to tell which Item was chosen; for example, when a MenuLine()
+// has a newline in the middle of it (dmenu would show it as two lines).
+//
+var ErrAmbiguous = errors.New("dmx: menu lines can't be told apart")
+
+// Autoconfigure() configures the Default Menu; see Menu.Autoconfigure().
 //
 func Autoconfigure(other_cfgs []string) error {
-    dconfig.Reset()
-    dconfig.AddString(&DmenuPath,  "dmenu",       dconfig.STRIP)
-    dconfig.AddString(&Font,       "font",        dconfig.STRIP)
-    dconfig.AddString(&NormalBG,   "normal_bg",   dconfig.STRIP)
-    dconfig.AddString(&NormalFG,   "normal_fg",   dconfig.STRIP)
-    dconfig.AddString(&SelectedBG, "selected_bg", dconfig.STRIP)
-    dconfig.AddString(&SelectedFG, "selected_fg", dconfig.STRIP)
-    
-    cfg_files := make([]string, 0, 2)
-    for _, fname := range other_cfgs {
-        cfg_files = append(cfg_files, fname)
-    }
-    cfg_files = append(cfg_files, os.ExpandEnv("$HOME/.config/dmx.conf"))
-    cfg_files = append(cfg_files, "/usr/share/dmx.conf")
-    
-    err := dconfig.Configure(cfg_files, false)
-    
-    return err
+    return Default.Autoconfigure(other_cfgs)
 }
 
 // Interface Item represents a single menu item to be passed to dmenu.
@@ -64,11 +51,13 @@ func Autoconfigure(other_cfgs []string) error {
 // key     more thorough description of item
 //
 // Item.MenuLine() Should return a []byte of a similar format. It takes an int
-// meant to represent the maximum key length among all the Items displayed by
-// dmenu, so that all the descriptions can be lined up properly.
+// meant to represent the maximum key width among all the Items displayed by
+// dmenu, so that all the descriptions can be lined up properly. The width is
+// measured in screen columns (see Width()), not bytes, so use Pad() rather
+// than fmt's %-*s to line things up.
 //
 // Item.Key() should return the key so that DmenuSelect() can calculate the
-// maximum key length of all the items to be displayed.
+// maximum key width of all the items to be displayed.
 //
 // Item.SortsBefore() allows you to make slices of Item sortable by
 // implementing a single method on Item rather than three methods on
@@ -77,6 +66,10 @@ func Autoconfigure(other_cfgs []string) error {
 // 
 // func (mi MyItem) SortsBefore(itm dmx.Item) bool { return false }
 //
+// An Item can also implement Iconer, Urgenter, Activer or SearchTexter, for
+// the backends that can show such things (like rofi); the others ignore
+// them.
+//
 type Item interface {
     Key() string
     MenuLine(int) []byte
@@ -96,7 +89,7 @@ func (il ItemList) keyLen() int {
     kl := 0
     var nl int
     for _, itm := range il {
-        nl = len(itm.Key())
+        nl = Width(itm.Key())
         if nl > kl {
             kl = nl
         }
@@ -119,42 +112,58 @@ func ensureReturnminated(bs []byte) []byte {
     }
 }
 
-// DmenuSelect() runs dmenu externally to allow the user to select one of the
-// Items in the supplied ItemList.
+// Result describes how the user answered a menu.
+//
+// If the user chose one of the offered Items, Item holds it. If the user
+// instead typed something that matches none of the menu lines, Item is nil
+// and Typed holds the text (without the trailing newline). If the user
+// dismissed the menu, Cancelled is true and both of the others are empty.
+//
+type Result struct {
+    Item      Item
+    Typed     string
+    Cancelled bool
+    n         int           // Item's index in the menu's ItemList
+}
+
+// DmenuSelect() runs dmenu (or the configured Backend) externally to allow
+// the user to select one of the Items in the supplied ItemList.
 //
-func DmenuSelect(prompt string, input ItemList) (Item, error) {
-    key_len := input.keyLen()
-    menu_lines := make([][]byte, 0, len(input))
-    for _, itm := range input {
-        menu_lines = append(menu_lines, ensureReturnminated(itm.MenuLine(key_len)))
-    }
-    
-    var dmenu_input  = new(bytes.Buffer)
-    var dmenu_output = new(bytes.Buffer)
-    for _, ml := range menu_lines {
-        dmenu_input.Write(ml)
-    }
-    
-    dcmd := exec.Command(DmenuPath,
-                         "-l", fmt.Sprintf("%d", len(input)),
-                         "-p",  prompt,     "-fn", Font,
-                         "-nb", NormalBG,   "-nf", NormalFG,
-                         "-sb", SelectedBG, "-sf", SelectedFG)
-    dcmd.Stdin  = dmenu_input
-    dcmd.Stdout = dmenu_output
-    err := dcmd.Run()
-    if err != nil {
-        return nil, err
-    }
-    
-    stdout_bytes := dmenu_output.Bytes()
-    for n, ml := range menu_lines {
-        if bytes.Equal(stdout_bytes, ml) {
-            return input[n], nil
-        }
-    }
-    
-    return nil, nil
+// If the user dismisses the menu, the error is ErrCancelled. If the user
+// types something that doesn't match any of the Items, both return values
+// are nil; use Choose() if you need to know what was typed.
+//
+// This, and the other package-level functions below, use the Default Menu.
+// Any Options override its settings for this one menu.
+//
+func DmenuSelect(prompt string, input ItemList, opts ...Option) (Item, error) {
+    return Default.Select(prompt, input, opts...)
+}
+
+// DmenuSelectContext() is like DmenuSelect(), but the dmenu process is
+// killed if ctx is cancelled or its deadline passes before the user makes
+// a selection. In that case the error returned is ctx.Err() (that is,
+// context.Canceled or context.DeadlineExceeded), so callers can tell it
+// apart from the user dismissing the menu.
+//
+func DmenuSelectContext(ctx context.Context, prompt string, input ItemList, opts ...Option) (Item, error) {
+    return Default.SelectContext(ctx, prompt, input, opts...)
+}
+
+// Choose() is like DmenuSelect(), but reports the user's answer as a
+// Result, so that dismissing the menu, choosing an Item and typing free
+// text can each be handled on purpose. Dismissing the menu is not an error
+// here; it just sets Result.Cancelled.
+//
+func Choose(prompt string, input ItemList, opts ...Option) (Result, error) {
+    return Default.Choose(prompt, input, opts...)
+}
+
+// ChooseContext() is to Choose() as DmenuSelectContext() is to
+// DmenuSelect().
+//
+func ChooseContext(ctx context.Context, prompt string, input ItemList, opts ...Option) (Result, error) {
+    return Default.ChooseContext(ctx, prompt, input, opts...)
 }
 
 // Run() is a more primitive interface to dmenu than DmenuSelect().
@@ -162,24 +171,17 @@ func DmenuSelect(prompt string, input ItemList) (Item, error) {
 // to pass to dmenu and returns the output of dmenu (which WILL be
 // newline-terminated).
 //
-func Run(prompt string, input [][]byte) ([]byte, error) {
-    
-    dcmd := exec.Command(DmenuPath, "-l", fmt.Sprintf("%d", len(input)),
-                                    "-p", prompt,
-                                    "-fn", Font,
-                                    "-nb", NormalBG, "-nf", NormalFG,
-                                    "-sb", SelectedBG, "-sf", SelectedFG)
-    
-    stdin_slice := make([]byte, 0)
-    for _, bs := range input {
-        stdin_slice = append(stdin_slice, bs...)
-        stdin_slice = append(stdin_slice, CRLF...)
-    }
-    dcmd.Stdin = bytes.NewReader(stdin_slice)
-    var stdout_bytes bytes.Buffer
-    dcmd.Stdout = &stdout_bytes
-    err := dcmd.Run()
-    return stdout_bytes.Bytes(), err
+func Run(prompt string, input [][]byte, opts ...Option) ([]byte, error) {
+    return Default.Run(prompt, input, opts...)
+}
+
+// RunContext() is to Run() as DmenuSelectContext() is to DmenuSelect().
+//
+// As with the other functions, the error is ErrCancelled if the user
+// dismisses the menu.
+//
+func RunContext(ctx context.Context, prompt string, input [][]byte, opts ...Option) ([]byte, error) {
+    return Default.RunContext(ctx, prompt, input, opts...)
 }
 
 func init() {
@@ -0,0 +1,165 @@
+// dmx_bench_test.go
+//
+// Benchmarks for big menus.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bytes"; "context"; "fmt"; "io"; "os/exec"; "testing" )
+
+// benchSizes are the menu sizes the benchmarks are run at.
+//
+var benchSizes = []int{ 10000, 100000, 1000000 }
+
+type benchItem struct {
+    key  string
+    desc string
+}
+
+func (bi *benchItem) Key() string { return bi.key }
+func (bi *benchItem) MenuLine(w int) []byte {
+    return []byte(Pad(bi.key, w) + "    " + bi.desc)
+}
+func (bi *benchItem) SortsBefore(itm Item) bool { return bi.key < itm.(*benchItem).key }
+
+var benchLists = map[int]ItemList{}
+
+func benchList(n int) ItemList {
+    if il, ok := benchLists[n]; ok {
+        return il
+    }
+    il := make(ItemList, n)
+    for i := range il {
+        il[i] = &benchItem{ key: fmt.Sprintf("%07d", i),
+                            desc: fmt.Sprintf("/home/user/some/directory/file-%d.txt", i) }
+    }
+    benchLists[n] = il
+    return il
+}
+
+// tailBackend stands in for dmenu: it runs tail(1), which reads the whole
+// menu and then "chooses" the last line (the worst case for finding the
+// chosen line by searching).
+//
+type tailBackend struct{}
+
+func (tailBackend) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    return runExec(ctx, "tail", []string{ "-n", "1" }, input)
+}
+
+func needTail(b *testing.B) {
+    if _, err := exec.LookPath("tail"); err != nil {
+        b.Skip("no tail(1) to stand in for dmenu")
+    }
+}
+
+// selectBuffered() is how Select() used to work, for comparison: it builds
+// every menu line, copies them all into a buffer (numbering duplicates, with
+// a map[string]int to find them), and only then starts the menu program.
+//
+func selectBuffered(be Backend, input ItemList) (Item, error) {
+    key_len := input.keyLen()
+    pos := make(map[string]int, len(input))
+    var dmenu_input = new(bytes.Buffer)
+    for n, itm := range input {
+        ml := ensureReturnminated(itm.MenuLine(key_len))
+        if _, taken := pos[string(ml)]; taken {
+            body := ml[:len(ml)-crlfLength]
+            for k := 2; taken; k++ {
+                ml = []byte(fmt.Sprintf("%s [%d]%s", body, k, CRLF))
+                _, taken = pos[string(ml)]
+            }
+        }
+        pos[string(ml)] = n
+        dmenu_input.Write(ml)
+    }
+    out, err := be.Run(context.Background(), &Options{ Lines: len(input) }, dmenu_input)
+    if err != nil {
+        return nil, err
+    }
+    if n, found := pos[string(out)]; found {
+        return input[n], nil
+    }
+    return nil, nil
+}
+
+func BenchmarkSelect(b *testing.B) {
+    needTail(b)
+    m := NewMenu()
+    m.Backend = tailBackend{}
+    for _, n := range benchSizes {
+        input := benchList(n)
+        b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                itm, err := m.Select("", input)
+                if err != nil || itm != input[n-1] {
+                    b.Fatalf("got %v, %v", itm, err)
+                }
+            }
+        })
+    }
+}
+
+func BenchmarkSelectBuffered(b *testing.B) {
+    needTail(b)
+    for _, n := range benchSizes {
+        input := benchList(n)
+        b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                itm, err := selectBuffered(tailBackend{}, input)
+                if err != nil || itm != input[n-1] {
+                    b.Fatalf("got %v, %v", itm, err)
+                }
+            }
+        })
+    }
+}
+
+func benchLines(n int) [][]byte {
+    lines := make([][]byte, n)
+    for i := range lines {
+        lines[i] = []byte(fmt.Sprintf("%07d    /home/user/some/directory/file-%d.txt", i, i))
+    }
+    return lines
+}
+
+func BenchmarkRun(b *testing.B) {
+    needTail(b)
+    m := NewMenu()
+    m.Backend = tailBackend{}
+    for _, n := range benchSizes {
+        input := benchLines(n)
+        b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                if _, err := m.Run("", input); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
+
+// BenchmarkRunAppended is how Run() used to build its input, for
+// comparison.
+//
+func BenchmarkRunAppended(b *testing.B) {
+    needTail(b)
+    for _, n := range benchSizes {
+        input := benchLines(n)
+        b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                stdin_slice := make([]byte, 0)
+                for _, bs := range input {
+                    stdin_slice = append(stdin_slice, bs...)
+                    stdin_slice = append(stdin_slice, CRLF...)
+                }
+                _, err := tailBackend{}.Run(context.Background(), &Options{},
+                                            bytes.NewReader(stdin_slice))
+                if err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,309 @@
+// dmxtest.go
+//
+// A scripted, in-process dmx.Backend for testing code built on dmx.
+//
+// https://github.com/d2718/dmx
+//
+// Package dmxtest provides a fake dmx.Backend that answers menus from a
+// script instead of putting anything on the screen, so code that calls
+// dmx.DmenuSelect() and friends can be tested without an X display or a
+// real dmenu. For example:
+//
+//    fake := dmxtest.New(
+//        dmxtest.Expect("execute: ", dmxtest.Pick("calc")),
+//        dmxtest.Expect("execute: ", dmxtest.Cancel()),
+//    )
+//    defer dmxtest.Install(fake)()
+//
+//    // ... run the code under test ...
+//
+//    if err := fake.Done(); err != nil {
+//        t.Fatal(err)
+//    }
+//    // fake.Shown holds every menu that was displayed.
+//
+package dmxtest
+
+import( "bufio"; "bytes"; "context"; "fmt"; "io"; "strings"; "sync"
+        "github.com/d2718/dmx"
+)
+
+type answerKind int
+
+const(
+    pickKey answerKind = iota
+    pickIndex
+    pickMany
+    typeText
+    cancel
+)
+
+// An Answer is what the fake "user" does with a menu.
+//
+type Answer struct {
+    kind  answerKind
+    key   string
+    keys  []string
+    index int
+}
+
+// Pick() chooses the menu line whose key is key: that is, the line that is
+// exactly key, or whose first whitespace-separated field is key.
+//
+func Pick(key string) Answer { return Answer{ kind: pickKey, key: key } }
+
+// PickIndex() chooses the n-th menu line (counting from zero).
+//
+func PickIndex(n int) Answer { return Answer{ kind: pickIndex, index: n } }
+
+// PickMany() chooses the lines with each of the given keys (see Pick()),
+// in order, from a menu from which several can be chosen (see
+// dmx.DmenuSelectMulti()).
+//
+func PickMany(keys ...string) Answer { return Answer{ kind: pickMany, keys: keys } }
+
+// Type() answers with free text instead of choosing a line.
+//
+func Type(text string) Answer { return Answer{ kind: typeText, key: text } }
+
+// Cancel() dismisses the menu, as if the user hit Escape.
+//
+func Cancel() Answer { return Answer{ kind: cancel } }
+
+func (a Answer) String() string {
+    switch a.kind {
+        case pickKey:
+            return fmt.Sprintf("Pick(%q)", a.key)
+        case pickIndex:
+            return fmt.Sprintf("PickIndex(%d)", a.index)
+        case pickMany:
+            quoted := make([]string, len(a.keys))
+            for i, k := range a.keys {
+                quoted[i] = fmt.Sprintf("%q", k)
+            }
+            return "PickMany(" + strings.Join(quoted, ", ") + ")"
+        case typeText:
+            return fmt.Sprintf("Type(%q)", a.key)
+        default:
+            return "Cancel()"
+    }
+}
+
+// A Step is one menu the script expects to be shown, and how to answer it.
+//
+type Step struct {
+    Prompt    string
+    AnyPrompt bool
+    Answer    Answer
+}
+
+// Expect() makes a Step that expects a menu with the given prompt.
+//
+func Expect(prompt string, a Answer) Step {
+    return Step{ Prompt: prompt, Answer: a }
+}
+
+// Any() makes a Step that doesn't care what the prompt is.
+//
+func Any(a Answer) Step {
+    return Step{ AnyPrompt: true, Answer: a }
+}
+
+// Shown is the record of a single menu that was displayed. Password is
+// whether what was typed into it was to be hidden (see dmx.Password()).
+//
+type Shown struct {
+    Options  dmx.Options
+    Lines    []string       // without their trailing newlines
+    Password bool
+}
+
+// Backend is the fake. It implements dmx.Backend, and is safe to use from
+// more than one goroutine, although the order of the script then depends
+// on the order in which the menus happen to be shown.
+//
+// By default it reports the index of the chosen line (dmx.FeatIndex), so
+// the lines it is shown are exactly the Items' MenuLine()s, and accepts all
+// of the Items' extras (dmx.FeatRows) and default (dmx.FeatSelect), which
+// end up in Shown's Options.Rows and Options.Selected. It always lets
+// several lines be chosen (dmx.FeatMulti), for PickMany(). Set NoIndex to
+// make it behave like plain dmenu, which only prints the chosen line and
+// has no extras.
+//
+// Set Password to make it able to hide what's typed (dmx.FeatPassword),
+// so dmx.Password() can be used with it; answer with Type().
+//
+type Backend struct {
+    NoIndex  bool
+    Password bool
+    mu       sync.Mutex
+    script   []Step
+    Shown    []Shown
+    err      error
+}
+
+// New() returns a fake Backend that will answer menus according to the
+// given steps, in order.
+//
+func New(steps ...Step) *Backend {
+    return &Backend{ script: steps }
+}
+
+// Install() makes b the Backend of dmx.Default (the Menu the package-level
+// functions use), and returns a function that puts back whatever was there
+// before (suitable for defer). To test code that uses its own dmx.Menu,
+// just set that Menu's Backend field.
+//
+func Install(b dmx.Backend) func() {
+    old := dmx.Default.Backend
+    dmx.Default.Backend = b
+    return func() { dmx.Default.Backend = old }
+}
+
+func (b *Backend) Features() dmx.Feature {
+    f := dmx.FeatMulti
+    if !b.NoIndex {
+        f |= dmx.FeatIndex | dmx.FeatRows | dmx.FeatSelect
+    }
+    if b.Password {
+        f |= dmx.FeatPassword
+    }
+    return f
+}
+
+func (b *Backend) Run(ctx context.Context, opts *dmx.Options, input io.Reader) ([]byte, error) {
+    lines := make([]string, 0)
+    scnr := bufio.NewScanner(input)
+    scnr.Buffer(nil, 1024*1024)
+    for scnr.Scan() {
+        lines = append(lines, scnr.Text())
+    }
+    if err := scnr.Err(); err != nil {
+        return nil, err
+    }
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+    
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.Shown = append(b.Shown, Shown{ Options: *opts, Lines: lines, Password: opts.Password })
+    n := len(b.Shown)
+    
+    if b.err != nil {
+        return nil, b.err
+    }
+    if len(b.script) == 0 {
+        return nil, b.fail("menu %d (%q): not in script", n, opts.Prompt)
+    }
+    step := b.script[0]
+    b.script = b.script[1:]
+    
+    if !step.AnyPrompt && step.Prompt != opts.Prompt {
+        return nil, b.fail("menu %d: expected prompt %q, got %q",
+                           n, step.Prompt, opts.Prompt)
+    }
+    
+    a := step.Answer
+    switch a.kind {
+        case cancel:
+            return nil, dmx.ErrCancelled
+        case typeText:
+            return output(opts, -1, a.key), nil
+        case pickIndex:
+            if a.index < 0 || a.index >= len(lines) {
+                return nil, b.fail("menu %d (%q): %v, but there are only %d lines",
+                                   n, opts.Prompt, a, len(lines))
+            }
+            return output(opts, a.index, lines[a.index]), nil
+        case pickMany:
+            if len(a.keys) > 1 && !opts.Multi {
+                return nil, b.fail("menu %d (%q): %v, but only one line can be chosen",
+                                   n, opts.Prompt, a)
+            }
+            var out []byte
+            for _, k := range a.keys {
+                i, ok := findKey(lines, k)
+                if !ok {
+                    return nil, b.fail("menu %d (%q): %v, but no line has the key %q",
+                                       n, opts.Prompt, a, k)
+                }
+                out = append(out, output(opts, i, lines[i])...)
+            }
+            return out, nil
+        default:
+            if i, ok := findKey(lines, a.key); ok {
+                return output(opts, i, lines[i]), nil
+            }
+            return nil, b.fail("menu %d (%q): %v, but no line has that key",
+                               n, opts.Prompt, a)
+    }
+}
+
+// findKey() returns the index of the line that is exactly key, or else of
+// the first one whose first field is key.
+//
+func findKey(lines []string, key string) (int, bool) {
+    for i, ln := range lines {
+        if ln == key {
+            return i, true
+        }
+    }
+    for i, ln := range lines {
+        f := strings.Fields(ln)
+        if len(f) > 0 && f[0] == key {
+            return i, true
+        }
+    }
+    return 0, false
+}
+
+// output() formats an answer the way dmx asked for it: the line itself,
+// or its index and text (see dmx.Options.Index).
+//
+func output(opts *dmx.Options, idx int, text string) []byte {
+    if opts.Index {
+        return []byte(fmt.Sprintf("%d %s\n", idx, text))
+    }
+    return []byte(text + "\n")
+}
+
+// fail() records the first thing to go wrong with the script and returns
+// it. Once the script has failed, every later menu fails the same way.
+//
+func (b *Backend) fail(msgfmt string, args ...interface{}) error {
+    b.err = fmt.Errorf("dmxtest: " + msgfmt, args...)
+    return b.err
+}
+
+// Err() returns the first way in which the menus shown didn't match the
+// script, or nil.
+//
+func (b *Backend) Err() error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.err
+}
+
+// Done() is like Err(), but also complains if any of the script is left.
+//
+func (b *Backend) Done() error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.err != nil {
+        return b.err
+    }
+    if len(b.script) > 0 {
+        var buff bytes.Buffer
+        for _, s := range b.script {
+            if s.AnyPrompt {
+                fmt.Fprintf(&buff, " %v", s.Answer)
+            } else {
+                fmt.Fprintf(&buff, " %q:%v", s.Prompt, s.Answer)
+            }
+        }
+        return fmt.Errorf("dmxtest: %d step(s) never shown:%s",
+                          len(b.script), buff.String())
+    }
+    return nil
+}
@@ -0,0 +1,216 @@
+// dmxtest_test.go
+//
+// Tests for the fake Backend, driven through dmx itself.
+//
+// https://github.com/d2718/dmx
+//
+package dmxtest_test
+
+import( "strings"; "testing"
+        "github.com/d2718/dmx"
+        "github.com/d2718/dmx/dmxtest"
+)
+
+// A cmd is an Item whose menu line is its name and a description.
+//
+type cmd struct {
+    name string
+    desc string
+}
+
+func (c *cmd) Key() string { return c.name }
+func (c *cmd) MenuLine(w int) []byte { return []byte(dmx.Pad(c.name, w) + "  " + c.desc) }
+func (c *cmd) SortsBefore(itm dmx.Item) bool { return c.name < itm.(*cmd).name }
+
+var calc, edit, term = &cmd{ "calc", "calculator" }, &cmd{ "edit", "text editor" },
+                       &cmd{ "term", "terminal" }
+
+func cmds() dmx.ItemList { return dmx.ItemList{ calc, edit, term } }
+
+// Each test is run with the fake reporting indices, and again acting like
+// plain dmenu.
+//
+func bothWays(t *testing.T, test func(t *testing.T, no_index bool)) {
+    t.Run("index", func(t *testing.T) { test(t, false) })
+    t.Run("no index", func(t *testing.T) { test(t, true) })
+}
+
+func TestSelectPick(t *testing.T) {
+    bothWays(t, func(t *testing.T, no_index bool) {
+        fake := dmxtest.New(
+            dmxtest.Expect("run: ", dmxtest.Pick("edit")),
+            dmxtest.Expect("run: ", dmxtest.PickIndex(2)),
+        )
+        fake.NoIndex = no_index
+        defer dmxtest.Install(fake)()
+        
+        for _, want := range []dmx.Item{ edit, term } {
+            itm, err := dmx.DmenuSelect("run: ", cmds())
+            if err != nil {
+                t.Fatal(err)
+            }
+            if itm != want {
+                t.Errorf("DmenuSelect() returned %v, expected %v", itm, want)
+            }
+        }
+        if err := fake.Done(); err != nil {
+            t.Error(err)
+        }
+        if len(fake.Shown) != 2 {
+            t.Fatalf("%d menus shown, expected 2", len(fake.Shown))
+        }
+        if s := fake.Shown[0]; s.Options.Prompt != "run: " || len(s.Lines) != 3 ||
+                                !strings.HasPrefix(s.Lines[1], "edit") {
+            t.Errorf("menu shown as %q: %q", s.Options.Prompt, s.Lines)
+        }
+    })
+}
+
+func TestChoose(t *testing.T) {
+    bothWays(t, func(t *testing.T, no_index bool) {
+        fake := dmxtest.New(
+            dmxtest.Any(dmxtest.Pick("calc")),
+            dmxtest.Any(dmxtest.Type("firefox")),
+            dmxtest.Any(dmxtest.Cancel()),
+        )
+        fake.NoIndex = no_index
+        defer dmxtest.Install(fake)()
+        
+        want := []dmx.Result{
+            { Item: calc },
+            { Typed: "firefox" },
+            { Cancelled: true },
+        }
+        for n, w := range want {
+            res, err := dmx.Choose("run: ", cmds())
+            if err != nil {
+                t.Fatal(err)
+            }
+            if res.Item != w.Item || res.Typed != w.Typed || res.Cancelled != w.Cancelled {
+                t.Errorf("menu %d: Choose() returned %+v, expected %+v", n+1, res, w)
+            }
+        }
+        if err := fake.Done(); err != nil {
+            t.Error(err)
+        }
+    })
+}
+
+func TestSelectTypeAndCancel(t *testing.T) {
+    fake := dmxtest.New(
+        dmxtest.Expect("run: ", dmxtest.Type("nothing like it")),
+        dmxtest.Expect("run: ", dmxtest.Cancel()),
+    )
+    defer dmxtest.Install(fake)()
+    
+    if itm, err := dmx.DmenuSelect("run: ", cmds()); itm != nil || err != nil {
+        t.Errorf("typing: DmenuSelect() returned %v, %v; expected nil, nil", itm, err)
+    }
+    if itm, err := dmx.DmenuSelect("run: ", cmds()); itm != nil || err != dmx.ErrCancelled {
+        t.Errorf("cancelling: DmenuSelect() returned %v, %v; expected nil, ErrCancelled", itm, err)
+    }
+    if err := fake.Done(); err != nil {
+        t.Error(err)
+    }
+}
+
+func TestSelectMulti(t *testing.T) {
+    bothWays(t, func(t *testing.T, no_index bool) {
+        fake := dmxtest.New(
+            dmxtest.Expect("delete: ", dmxtest.PickMany("term", "calc", "term")),
+            dmxtest.Expect("delete: ", dmxtest.Pick("edit")),
+        )
+        fake.NoIndex = no_index
+        defer dmxtest.Install(fake)()
+        
+        for _, want := range [][]dmx.Item{ { term, calc }, { edit } } {
+            chosen, err := dmx.DmenuSelectMulti("delete: ", cmds())
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(chosen) != len(want) {
+                t.Fatalf("DmenuSelectMulti() returned %v, expected %v", chosen, want)
+            }
+            for n := range want {
+                if chosen[n] != want[n] {
+                    t.Errorf("DmenuSelectMulti() returned %v, expected %v", chosen, want)
+                }
+            }
+        }
+        if err := fake.Done(); err != nil {
+            t.Error(err)
+        }
+        if !fake.Shown[0].Options.Multi {
+            t.Error("the menu wasn't shown as a multiple-choice menu")
+        }
+    })
+}
+
+func TestPickManySingle(t *testing.T) {
+    fake := dmxtest.New(dmxtest.Any(dmxtest.PickMany("calc", "edit")))
+    defer dmxtest.Install(fake)()
+    if _, err := dmx.DmenuSelect("run: ", cmds()); err == nil {
+        t.Error("PickMany() of two lines from an ordinary menu didn't fail")
+    }
+}
+
+func TestPassword(t *testing.T) {
+    fake := dmxtest.New(dmxtest.Expect("password: ", dmxtest.Type("hunter2")))
+    defer dmxtest.Install(fake)()
+    if _, err := dmx.Password("password: "); err != dmx.ErrNoPassword {
+        t.Errorf("Password() without Password set returned %v, expected ErrNoPassword", err)
+    }
+    
+    fake.Password = true
+    pw, err := dmx.Password("password: ")
+    if err != nil || pw != "hunter2" {
+        t.Errorf("Password() returned %q, %v; expected \"hunter2\", nil", pw, err)
+    }
+    if err := fake.Done(); err != nil {
+        t.Error(err)
+    }
+    if len(fake.Shown) != 1 || !fake.Shown[0].Password {
+        t.Error("the menu wasn't asked to hide what was typed")
+    }
+}
+
+func TestDone(t *testing.T) {
+    t.Run("steps left over", func(t *testing.T) {
+        fake := dmxtest.New(dmxtest.Any(dmxtest.Pick("calc")), dmxtest.Any(dmxtest.Cancel()))
+        defer dmxtest.Install(fake)()
+        dmx.DmenuSelect("run: ", cmds())
+        if err := fake.Done(); err == nil || !strings.Contains(err.Error(), "never shown") {
+            t.Errorf("Done() returned %v, expected a step never shown", err)
+        }
+    })
+    t.Run("wrong prompt", func(t *testing.T) {
+        fake := dmxtest.New(dmxtest.Expect("run: ", dmxtest.Pick("calc")))
+        defer dmxtest.Install(fake)()
+        if _, err := dmx.DmenuSelect("open: ", cmds()); err == nil {
+            t.Error("DmenuSelect() with the wrong prompt didn't fail")
+        }
+        if err := fake.Done(); err == nil || !strings.Contains(err.Error(), "expected prompt") {
+            t.Errorf("Done() returned %v, expected a wrong prompt", err)
+        }
+    })
+    t.Run("not in script", func(t *testing.T) {
+        fake := dmxtest.New()
+        defer dmxtest.Install(fake)()
+        if _, err := dmx.DmenuSelect("run: ", cmds()); err == nil {
+            t.Error("DmenuSelect() with nothing in the script didn't fail")
+        }
+        if err := fake.Done(); err == nil || !strings.Contains(err.Error(), "not in script") {
+            t.Errorf("Done() returned %v, expected a menu not in the script", err)
+        }
+    })
+    t.Run("no such line", func(t *testing.T) {
+        fake := dmxtest.New(dmxtest.Any(dmxtest.Pick("vi")))
+        defer dmxtest.Install(fake)()
+        if _, err := dmx.DmenuSelect("run: ", cmds()); err == nil {
+            t.Error("DmenuSelect() picking a missing key didn't fail")
+        }
+        if fake.Done() == nil {
+            t.Error("Done() returned nil after picking a missing key")
+        }
+    })
+}
@@ -0,0 +1,178 @@
+// form.go
+//
+// Asking for several things, one menu after another.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "context"; "errors"; "fmt"; "strings" )
+
+// A Field is one of the things a Form() asks for.
+//
+// Name is what the answer is returned under, and what it's called on the
+// summary screen; Prompt is what the user is asked (Name and ": ", if it's
+// empty). Default is the suggested answer (see Prompt()). If Validate
+// isn't nil, it's given each answer, and if it returns an error, the
+// question is asked again with the error in the prompt.
+//
+type Field struct {
+    Name     string
+    Prompt   string
+    Default  string
+    Validate func(string) error
+}
+
+func (f Field) prompt() string {
+    if f.Prompt != "" {
+        return f.Prompt
+    }
+    return f.Name + ": "
+}
+
+// FormPrompt is the prompt of the summary screen at the end of a Form(),
+// and FormDone is the line on it that finishes the Form.
+//
+var(
+    FormPrompt = "ok? "
+    FormDone   = "[ done ]"
+)
+
+// NotEmpty() is a Field.Validate function for answers that can't be left
+// blank.
+//
+func NotEmpty(s string) error {
+    if strings.TrimSpace(s) == "" {
+        return errors.New("can't be empty")
+    }
+    return nil
+}
+
+// A formLine is a line of a Form()'s summary screen: a Field and its
+// answer, or (if n is -1) FormDone.
+//
+type formLine struct {
+    n     int
+    name  string
+    value string
+}
+
+func (fl *formLine) Key() string { return fl.name }
+func (fl *formLine) MenuLine(w int) []byte {
+    if fl.n < 0 {
+        return []byte(FormDone)
+    }
+    return []byte(Pad(fl.name, w) + "  " + fl.value)
+}
+func (fl *formLine) SortsBefore(Item) bool { return false }
+
+// Form() asks for each of the fields in turn. See the package-level
+// Form().
+//
+func (m *Menu) Form(fields []Field, opts ...Option) (map[string]string, error) {
+    return m.FormContext(context.Background(), fields, opts...)
+}
+
+// FormContext() is like Form(), but gives up when ctx finishes.
+//
+func (m *Menu) FormContext(ctx context.Context, fields []Field, opts ...Option) (map[string]string, error) {
+    values := make(map[string]string, len(fields))
+    answered := make([]bool, len(fields))
+    problem := make([]error, len(fields))
+    var rejected string     // the answer problem[n] is about
+    editing := false        // whether a field was picked from the summary
+    
+    for n := 0; ; {
+        if n == len(fields) {
+            done := &formLine{ n: -1 }
+            list := ItemList{ done }
+            for i, f := range fields {
+                list = append(list, &formLine{ n: i, name: f.Name, value: values[f.Name] })
+            }
+            res, err := m.ChooseContext(ctx, FormPrompt, list, opts...)
+            if err != nil {
+                return nil, err
+            }
+            switch x := res.Item.(type) {
+                case nil:
+                    if res.Cancelled && n == 0 {
+                        return nil, ErrCancelled
+                    } else if res.Cancelled {
+                        n--
+                    }
+                case *formLine:
+                    if x == done {
+                        return values, nil
+                    }
+                    n, editing = x.n, true
+            }
+            continue
+        }
+    
+        f := fields[n]
+        prompt := f.prompt()
+        dflt := f.Default
+        if answered[n] {
+            dflt = values[f.Name]
+        }
+        if problem[n] != nil {
+            prompt = fmt.Sprintf("%s(%v) ", prompt, problem[n])
+            dflt = rejected
+        }
+        ans, err := m.PromptContext(ctx, prompt, dflt, opts...)
+        if err == ErrCancelled {
+            // Back to the summary, if that's where this came from, or
+            // else back to the field before, leaving the last good answer
+            // (if any) as it was.
+            problem[n] = nil
+            switch {
+                case editing:
+                    n, editing = len(fields), false
+                case n == 0:
+                    return nil, ErrCancelled
+                default:
+                    n--
+            }
+            continue
+        } else if err != nil {
+            return nil, err
+        }
+    
+        if f.Validate != nil {
+            if problem[n] = f.Validate(ans); problem[n] != nil {
+                rejected = ans
+                continue
+            }
+        }
+        values[f.Name], answered[n] = ans, true
+        if editing {
+            n, editing = len(fields), false
+        } else {
+            n++
+        }
+    }
+}
+
+// Form() asks for a series of things, one Prompt() after another, and
+// returns the answers, by Field.Name. Escape goes back to the Field before
+// (and, at the first one, dismisses the whole Form, in which case the
+// error is ErrCancelled). Once all the Fields are answered, a summary
+// screen shows them; choosing one of them asks for it again, Escape goes
+// back to the last one, and choosing FormDone returns the answers.
+//
+// For example,
+//
+//    ans, err := dmx.Form([]dmx.Field{
+//        { Name: "key", Validate: dmx.NotEmpty },
+//        { Name: "description" },
+//    })
+//
+func Form(fields []Field, opts ...Option) (map[string]string, error) {
+    return Default.Form(fields, opts...)
+}
+
+// FormContext() is to Form() as DmenuSelectContext() is to DmenuSelect().
+//
+func FormContext(ctx context.Context, fields []Field, opts ...Option) (map[string]string, error) {
+    return Default.FormContext(ctx, fields, opts...)
+}
@@ -0,0 +1,200 @@
+// history.go
+//
+// Remembering what gets chosen from menus.
+//
+// https://github.com/d2718/dmx
+//
+// Package history keeps track of how often, and how recently, things are
+// chosen from menus, so that the things chosen most often and most lately
+// (the most "frecent") can be put at the top. For example:
+//
+//    hist, err := history.Open("myprog")
+//    // ...
+//    id := func(itm dmx.Item) string { return itm.(*Bookmark).URL }
+//    sort.Sort(hist.Sorter(items, id))
+//    chosen, err := dmx.DmenuSelect("go: ", items)
+//    if chosen != nil {
+//        hist.Record(id(chosen))
+//        hist.Save()
+//    }
+//
+// Each Store is a JSON file in Dir(). The IDs are up to the caller; they
+// just have to stay the same from one run to the next (a path or a URL,
+// say, rather than a position in a list).
+//
+package history
+
+import( "encoding/json"; "fmt"; "os"; "path/filepath"; "sync"; "time"
+        "github.com/d2718/dmx"
+)
+
+// Dir() returns the directory where Stores are kept: $XDG_STATE_HOME/dmx,
+// where $XDG_STATE_HOME defaults to ~/.local/state.
+//
+func Dir() string {
+    state_home := os.Getenv("XDG_STATE_HOME")
+    if state_home == "" {
+        state_home = os.ExpandEnv("$HOME/.local/state")
+    }
+    return filepath.Join(state_home, "dmx")
+}
+
+// Use records how many times something has been chosen, and when it was
+// last chosen.
+//
+type Use struct {
+    Count int       `json:"count"`
+    Last  time.Time `json:"last"`
+}
+
+// Score() is u's frecency as of now: its Count, weighted by how long ago
+// it was Last used (the way Firefox ranks its address bar suggestions).
+//
+func (u Use) Score(now time.Time) float64 {
+    age := now.Sub(u.Last)
+    var weight float64
+    switch {
+        case age < 4 * 24 * time.Hour:  weight = 100
+        case age < 14 * 24 * time.Hour: weight = 70
+        case age < 31 * 24 * time.Hour: weight = 50
+        case age < 90 * 24 * time.Hour: weight = 30
+        default:                        weight = 10
+    }
+    return float64(u.Count) * weight
+}
+
+// A Store holds the Uses of a set of IDs. It is safe for concurrent use
+// within a program, but two programs Save()ing the same Store will
+// overwrite each other's changes.
+//
+type Store struct {
+    Path string
+    mu   sync.Mutex
+    uses map[string]Use
+}
+
+// Open() reads the named Store from Dir() (the file is name plus ".json").
+// If there isn't one yet, the Store starts out empty.
+//
+func Open(name string) (*Store, error) {
+    return OpenFile(filepath.Join(Dir(), name + ".json"))
+}
+
+// OpenFile() is like Open(), but reads (and will Save() to) the given path.
+//
+func OpenFile(path string) (*Store, error) {
+    s := &Store{ Path: path, uses: make(map[string]Use) }
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return s, nil
+    } else if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    if err := json.NewDecoder(f).Decode(&s.uses); err != nil {
+        return nil, fmt.Errorf("history: %s: %w", path, err)
+    }
+    if s.uses == nil {
+        s.uses = make(map[string]Use)
+    }
+    return s, nil
+}
+
+// Record() notes that id was just chosen.
+//
+func (s *Store) Record(id string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    u := s.uses[id]
+    u.Count++
+    u.Last = time.Now()
+    s.uses[id] = u
+}
+
+// Forget() removes id from the Store (for something that no longer
+// exists, say).
+//
+func (s *Store) Forget(id string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.uses, id)
+}
+
+// Get() returns the Use of id, and whether it has been used at all.
+//
+func (s *Store) Get(id string) (Use, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    u, ok := s.uses[id]
+    return u, ok
+}
+
+// Score() returns the current frecency of id (0 if it's never been used).
+//
+func (s *Store) Score(id string) float64 {
+    u, _ := s.Get(id)
+    return u.Score(time.Now())
+}
+
+// Save() writes the Store to its Path, creating the directory if it has
+// to. The file is replaced all at once, so a crash can't leave half of it.
+//
+func (s *Store) Save() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path) + ".*")
+    if err != nil {
+        return err
+    }
+    err = json.NewEncoder(tmp).Encode(s.uses)
+    if cerr := tmp.Close(); err == nil {
+        err = cerr
+    }
+    if err == nil {
+        err = os.Rename(tmp.Name(), s.Path)
+    }
+    if err != nil {
+        os.Remove(tmp.Name())
+    }
+    return err
+}
+
+// Frecency sorts an ItemList with the most frecent Items first. Items
+// with the same score (including all the ones that have never been
+// chosen) keep the order their SortsBefore() methods give them. Make one
+// with Store.Sorter().
+//
+type Frecency struct {
+    dmx.ItemList
+    scores []float64
+}
+
+// Sorter() returns a sort.Interface that sorts items by frecency; id
+// gives the ID each Item is recorded under.
+//
+func (s *Store) Sorter(items dmx.ItemList, id func(dmx.Item) string) Frecency {
+    now := time.Now()
+    scores := make([]float64, len(items))
+    s.mu.Lock()
+    for n, itm := range items {
+        scores[n] = s.uses[id(itm)].Score(now)
+    }
+    s.mu.Unlock()
+    return Frecency{ ItemList: items, scores: scores }
+}
+
+func (f Frecency) Less(i, j int) bool {
+    if f.scores[i] != f.scores[j] {
+        return f.scores[i] > f.scores[j]
+    }
+    return f.ItemList.Less(i, j)
+}
+
+func (f Frecency) Swap(i, j int) {
+    f.ItemList.Swap(i, j)
+    f.scores[i], f.scores[j] = f.scores[j], f.scores[i]
+}
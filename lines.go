@@ -0,0 +1,176 @@
+// lines.go
+//
+// Mapping what a menu program prints back to the line it was given.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bytes"; "fmt"; "hash/maphash"; "strconv" )
+
+// maxSuffix is how many numbered versions of a duplicated line lineIndex
+// will try before giving up.
+//
+const maxSuffix = 1 << 20
+
+// A lineIndex maps newline-terminated menu lines to the positions of the
+// Items they came from. Lines that have already been seen are changed (by
+// appending " [2]", " [3]", and so on) so that every line is unique.
+//
+// It's a hash table, but a simpler one than a map[string]int: the lines
+// are all kept end to end in one big slice rather than each in a string
+// of its own, and the table itself is one slice of uint64s. With hundreds
+// of thousands of lines, that's a lot less work for the allocator and the
+// garbage collector, and a lot fewer cache misses.
+//
+type lineIndex struct {
+    seed  maphash.Seed
+    slots []uint64          // see slot(); len is a power of 2
+    ents  []lineEnt
+    text  []byte
+    size  int               // how many lines are expected
+}
+
+// A lineEnt is a line in a lineIndex, which belongs to the Item at position
+// n. The line starts at text[start] and runs up to where the next one
+// starts.
+//
+type lineEnt struct {
+    hash  uint64
+    start int
+    n     int
+}
+
+func newLineIndex(size int) *lineIndex {
+    n_slots := 16
+    for n_slots < 2*size {
+        n_slots <<= 1
+    }
+    return &lineIndex{
+        seed:  maphash.MakeSeed(),
+        slots: make([]uint64, n_slots),
+        ents:  make([]lineEnt, 0, size),
+        size:  size,
+    }
+}
+
+// line() returns the text of the e-th line.
+//
+func (li *lineIndex) line(e int) []byte {
+    end := len(li.text)
+    if e+1 < len(li.ents) {
+        end = li.ents[e+1].start
+    }
+    return li.text[li.ents[e].start:end]
+}
+
+// slot() returns the index of the slot that holds line (whose hash is h),
+// or of the empty slot where it would go.
+//
+// A slot holds 1 + the line's index in ents in its low 32 bits (so an
+// empty one is 0), and the top half of the line's hash in the rest, so
+// that most of the lines that aren't the one being looked for can be
+// passed over without looking in ents.
+//
+func (li *lineIndex) slot(line []byte, h uint64) int {
+    mask := len(li.slots) - 1
+    tag := h &^ 0xffffffff
+    for i := int(h) & mask; ; i = (i + 1) & mask {
+        sl := li.slots[i]
+        if sl == 0 {
+            return i
+        }
+        if sl &^ 0xffffffff == tag {
+            e := int(sl & 0xffffffff) - 1
+            if bytes.Equal(li.line(e), line) {
+                return i
+            }
+        }
+    }
+}
+
+// slotValue() is what goes in the slot for the e-th line, whose hash is h.
+//
+func slotValue(e int, h uint64) uint64 {
+    return h &^ 0xffffffff | uint64(e + 1)
+}
+
+// find() returns the position of the Item line belongs to, if any.
+//
+func (li *lineIndex) find(line []byte) (int, bool) {
+    sl := li.slots[li.slot(line, maphash.Bytes(li.seed, line))]
+    if sl == 0 {
+        return 0, false
+    }
+    return li.ents[int(sl & 0xffffffff) - 1].n, true
+}
+
+// insert() puts line (whose hash is h) in the empty slot i, as belonging to
+// the Item at position n, making the table bigger if it's getting full.
+//
+func (li *lineIndex) insert(i int, line []byte, h uint64, n int) {
+    if li.text == nil && li.size > 0 {
+        // Guess that the lines will all be about as long as the first one
+        // (plus a bit), rather than letting append() grow text a little at
+        // a time, copying everything each time.
+        li.text = make([]byte, 0, li.size * (len(line) + 8))
+    }
+    li.ents = append(li.ents, lineEnt{ hash: h, start: len(li.text), n: n })
+    li.text = append(li.text, line...)
+    li.slots[i] = slotValue(len(li.ents) - 1, h)
+    if 2*len(li.ents) <= len(li.slots) {
+        return
+    }
+    li.slots = make([]uint64, 2*len(li.slots))
+    mask := len(li.slots) - 1
+    for e, ent := range li.ents {
+        j := int(ent.hash) & mask
+        for li.slots[j] != 0 {
+            j = (j + 1) & mask
+        }
+        li.slots[j] = slotValue(e, ent.hash)
+    }
+}
+
+// add() records that line (which must be newline-terminated) belongs to
+// the Item at position n, and returns the line as it should be shown.
+//
+func (li *lineIndex) add(line []byte, n int) ([]byte, error) {
+    body := line[:len(line)-crlfLength]
+    if bytes.Contains(body, CRLF) {
+        return nil, ErrAmbiguous
+    }
+    h := maphash.Bytes(li.seed, line)
+    if i := li.slot(line, h); li.slots[i] == 0 {
+        li.insert(i, line, h, n)
+        return line, nil
+    }
+    for k := 2; k < maxSuffix; k++ {
+        nu := []byte(fmt.Sprintf("%s [%d]%s", body, k, CRLF))
+        h = maphash.Bytes(li.seed, nu)
+        if i := li.slot(nu, h); li.slots[i] == 0 {
+            li.insert(i, nu, h, n)
+            return nu, nil
+        }
+    }
+    return nil, ErrAmbiguous
+}
+
+// lookup() returns the position of the Item whose line was output.
+//
+func (li *lineIndex) lookup(output []byte) (int, bool) {
+    return li.find(ensureReturnminated(output))
+}
+
+// parseIndexed() interprets the output of a Backend that was asked to
+// print indices (see Options.Index), returning the index and any text.
+//
+func parseIndexed(output []byte) (int, string, error) {
+    output = bytes.TrimSuffix(output, CRLF)
+    idx, text, _ := bytes.Cut(output, []byte(" "))
+    n, err := strconv.Atoi(string(idx))
+    if err != nil || n < -1 {
+        return 0, "", fmt.Errorf("dmx: backend printed %q instead of an index", output)
+    }
+    return n, string(text), nil
+}
@@ -0,0 +1,107 @@
+// lines_test.go
+//
+// Tests for lineIndex.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "fmt"; "testing" )
+
+// addAll() adds each of lines (without its newline) to li, as belonging to
+// the Item at its position, and returns the lines as they'd be shown
+// (again without newlines).
+//
+func addAll(t *testing.T, li *lineIndex, lines []string) []string {
+    shown := make([]string, len(lines))
+    for n, line := range lines {
+        out, err := li.add([]byte(line + "\n"), n)
+        if err != nil {
+            t.Fatalf("add(%q): %v", line, err)
+        }
+        shown[n] = string(out[:len(out)-1])
+    }
+    return shown
+}
+
+func TestLineIndexAdd(t *testing.T) {
+    tests := []struct {
+        name  string
+        lines []string
+        shown []string
+    }{
+        { "distinct", []string{ "foo", "bar", "baz" },
+                      []string{ "foo", "bar", "baz" } },
+        { "duplicates", []string{ "foo", "foo", "bar", "foo" },
+                        []string{ "foo", "foo [2]", "bar", "foo [3]" } },
+        { "empty lines", []string{ "", "" },
+                         []string{ "", " [2]" } },
+        { "literal suffix after", []string{ "foo", "foo", "foo [2]" },
+                                  []string{ "foo", "foo [2]", "foo [2] [2]" } },
+        { "literal suffix before", []string{ "foo [2]", "foo", "foo" },
+                                   []string{ "foo [2]", "foo", "foo [3]" } },
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            li := newLineIndex(len(tc.lines))
+            shown := addAll(t, li, tc.lines)
+            for n := range shown {
+                if shown[n] != tc.shown[n] {
+                    t.Errorf("line %d shown as %q, expected %q", n, shown[n], tc.shown[n])
+                }
+                if i, ok := li.lookup([]byte(shown[n])); !ok || i != n {
+                    t.Errorf("lookup(%q) = %d, %v; expected %d, true", shown[n], i, ok, n)
+                }
+            }
+        })
+    }
+}
+
+// With no idea how many lines are coming, the table starts at 16 slots and
+// has to grow (several times) to fit them all.
+//
+func TestLineIndexGrow(t *testing.T) {
+    li := newLineIndex(0)
+    lines := make([]string, 0)
+    for n := 0; n < 500; n++ {
+        lines = append(lines, fmt.Sprintf("line %d", n % 200))
+    }
+    shown := addAll(t, li, lines)
+    if len(li.slots) <= 16 {
+        t.Fatalf("table never grew: %d slots", len(li.slots))
+    }
+    seen := make(map[string]bool, len(shown))
+    for n, s := range shown {
+        if seen[s] {
+            t.Errorf("line %d shown as %q, which was already shown", n, s)
+        }
+        seen[s] = true
+        if i, ok := li.lookup([]byte(s)); !ok || i != n {
+            t.Errorf("lookup(%q) = %d, %v; expected %d, true", s, i, ok, n)
+        }
+    }
+    if shown[450] != "line 50 [3]" {
+        t.Errorf("line 450 shown as %q, expected \"line 50 [3]\"", shown[450])
+    }
+}
+
+func TestLineIndexNewline(t *testing.T) {
+    li := newLineIndex(1)
+    if _, err := li.add([]byte("two\nlines\n"), 0); err != ErrAmbiguous {
+        t.Errorf("add() of a line with a newline in it: got %v, expected ErrAmbiguous", err)
+    }
+}
+
+func TestLineIndexLookupMissing(t *testing.T) {
+    li := newLineIndex(2)
+    addAll(t, li, []string{ "foo", "foo" })
+    for _, s := range []string{ "bar", "fo", "foo [3]", "foo [2] ", "" } {
+        if i, ok := li.lookup([]byte(s)); ok {
+            t.Errorf("lookup(%q) found line %d, which isn't there", s, i)
+        }
+    }
+    // What the menu program prints ends in a newline, but it needn't.
+    if i, ok := li.lookup([]byte("foo [2]\n")); !ok || i != 1 {
+        t.Errorf("lookup(\"foo [2]\\n\") = %d, %v; expected 1, true", i, ok)
+    }
+}
@@ -0,0 +1,128 @@
+// loader.go
+//
+// One place for a program to get its settings from.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "flag"; "fmt"; "os"; "strings" )
+
+// A Loader reads a program's own settings from the same configuration file
+// as dmx's, along with the dmx settings for its menus. Each setting is
+// bound to a variable (whose value beforehand is the default), a key, and
+// optionally a command-line flag. Load() then gives each variable the
+// first value it finds, in order of precedence:
+//
+//   - the command-line flag, if it was given
+//   - the environment variable DMX_TOOL_KEY (for example, DMX_FDMCM_CLIP_DIR)
+//   - KEY in the [tool] section of the configuration file
+//   - TOOL_KEY, then KEY, at the top of the file (so a flat file written
+//     before there were sections, with keys like FDMCM_CLIP_DIR or EDITOR,
+//     still works)
+//   - the default
+//
+// The Menu is configured the same way: the dmx options at the top of the
+// file, overridden by any in the [tool] section, overridden by DMX_
+// environment variables. (See Menu.Autoconfigure().)
+//
+type Loader struct {
+    Tool  string
+    Menu  *Menu             // Default, unless set otherwise
+    Flags *flag.FlagSet     // flag.CommandLine, unless set otherwise
+    binds []binding
+}
+
+type binding struct {
+    key   string
+    flag  string
+    set   func(string) error
+}
+
+// NewLoader() returns a Loader for the named tool.
+//
+func NewLoader(tool string) *Loader {
+    return &Loader{ Tool: strings.ToLower(tool), Menu: Default, Flags: flag.CommandLine }
+}
+
+func (l *Loader) bind(key, flag_name string, set func(string) error) {
+    key = strings.ToLower(key)
+    l.binds = append(l.binds, binding{ key: key, flag: flag_name, set: set })
+    RegisterKeys(l.Tool, key)
+    RegisterKeys("", l.Tool + "_" + key, key)
+}
+
+// String() binds *p to key (and the command-line flag named flag_name,
+// which may be "" if there isn't one).
+//
+func (l *Loader) String(p *string, key, flag_name string) {
+    l.bind(key, flag_name, func(v string) error { *p = v; return nil })
+}
+
+// Bool() is like String(), but for a yes/no setting.
+//
+func (l *Loader) Bool(p *bool, key, flag_name string) {
+    l.bind(key, flag_name, func(v string) error {
+        b, err := parseBool(v)
+        if err == nil {
+            *p = b
+        }
+        return err
+    })
+}
+
+// Load() configures the Menu and sets all the bound variables. It should
+// be called after the flags have been parsed. other_cfgs are extra
+// configuration files to look in first, as for Autoconfigure().
+//
+// Like Autoconfigure(), Load() carries on past bad values, leaving those
+// variables alone, and returns the first error it came across.
+//
+func (l *Loader) Load(other_cfgs []string) error {
+    var first_err error
+    note := func(err error) {
+        if err != nil && first_err == nil {
+            first_err = err
+        }
+    }
+    
+    note(l.Menu.configure(other_cfgs, l.Tool))
+    
+    given := make(map[string]bool)
+    if l.Flags != nil {
+        l.Flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+    }
+    
+    var tool_sect, global map[string]confValue
+    var path string
+    if cf := l.Menu.conf; cf != nil {
+        tool_sect = cf.section(sectionName(l.Tool))
+        global = cf.section("")
+        path = cf.path
+    }
+    
+    for _, b := range l.binds {
+        if b.flag != "" && given[b.flag] {
+            continue
+        }
+        env := "DMX_" + strings.ToUpper(l.Tool + "_" + b.key)
+        if val, ok := os.LookupEnv(env); ok {
+            if err := b.set(strings.TrimSpace(val)); err != nil {
+                note(fmt.Errorf("$%s: bad value %q", env, val))
+            }
+            continue
+        }
+        for _, cv := range []confValue{ tool_sect[b.key],
+                                        global[l.Tool + "_" + b.key],
+                                        global[b.key] } {
+            if cv.line > 0 {
+                if err := b.set(cv.val); err != nil {
+                    note(fmt.Errorf("%s:%d: bad value %q for %s",
+                                    path, cv.line, cv.val, strings.ToUpper(b.key)))
+                }
+                break
+            }
+        }
+    }
+    return first_err
+}
@@ -0,0 +1,144 @@
+// loader_test.go
+//
+// Tests for Loader.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "flag"; "fmt"; "io"; "strings"; "testing" )
+
+// newTestLoader() returns a Loader for tool with its own Menu and FlagSet,
+// so nothing leaks into Default or flag.CommandLine.
+//
+func newTestLoader(tool string) *Loader {
+    l := NewLoader(tool)
+    l.Menu = NewMenu()
+    l.Flags = flag.NewFlagSet(tool, flag.ContinueOnError)
+    l.Flags.SetOutput(io.Discard)
+    return l
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+    // Each key is set at every level from the top of the list down to the
+    // one it's named after, so it should come out with that level's value.
+    fname := writeConf(t, `FLAG = top
+ENV = top
+SECTION = top
+TOOL_KEY = top
+KEY = top
+
+LT_FLAG = tool_key
+LT_ENV = tool_key
+LT_SECTION = tool_key
+LT_TOOL_KEY = tool_key
+
+[lt]
+FLAG = section
+ENV = section
+SECTION = section
+`)
+    t.Setenv("DMX_LT_FLAG", "env")
+    t.Setenv("DMX_LT_ENV", "env")
+    
+    l := newTestLoader("lt")
+    vals := make(map[string]*string)
+    for _, key := range []string{ "flag", "env", "section", "tool_key", "key", "default" } {
+        v := "default"
+        vals[key] = &v
+        l.Flags.StringVar(&v, key, v, "")
+        l.String(&v, key, key)
+    }
+    if err := l.Flags.Parse([]string{ "-flag", "flag" }); err != nil {
+        t.Fatal(err)
+    }
+    
+    if err := l.Load([]string{ fname }); err != nil {
+        t.Fatalf("Load(): %v", err)
+    }
+    for key, want := range map[string]string{
+        "flag":     "flag",
+        "env":      "env",
+        "section":  "section",
+        "tool_key": "tool_key",
+        "key":      "top",
+        "default":  "default",
+    } {
+        if got := *vals[key]; got != want {
+            t.Errorf("%s: got %q, want %q", key, got, want)
+        }
+    }
+}
+
+func TestLoaderFlagOverridesBadValue(t *testing.T) {
+    fname := writeConf(t, "LT2_QUIET = maybe\n")
+    l := newTestLoader("lt2")
+    quiet := false
+    l.Flags.BoolVar(&quiet, "q", false, "")
+    l.Bool(&quiet, "quiet", "q")
+    if err := l.Flags.Parse([]string{ "-q" }); err != nil {
+        t.Fatal(err)
+    }
+    if err := l.Load([]string{ fname }); err != nil {
+        t.Errorf("Load(): %v", err)
+    }
+    if !quiet {
+        t.Errorf("the flag didn't win")
+    }
+}
+
+func TestLoaderBadValue(t *testing.T) {
+    fname := writeConf(t, "# comment\n\n[lt3]\nQUIET = maybe\nNAME = x\n")
+    l := newTestLoader("lt3")
+    quiet := true
+    name := ""
+    l.Bool(&quiet, "quiet", "")
+    l.String(&name, "name", "")
+    
+    err := l.Load([]string{ fname })
+    if err == nil {
+        t.Fatal("Load() didn't complain about QUIET")
+    }
+    if want := fmt.Sprintf(`%s:4: bad value "maybe" for QUIET`, fname); err.Error() != want {
+        t.Errorf("got error %q, want %q", err, want)
+    }
+    if !quiet {
+        t.Errorf("QUIET was changed by a bad value")
+    }
+    if name != "x" {
+        t.Errorf("NAME wasn't loaded after the bad value: got %q", name)
+    }
+    
+    t.Setenv("DMX_LT3_QUIET", "perhaps")
+    err = l.Load([]string{ fname })
+    if err == nil || !strings.HasPrefix(err.Error(), "$DMX_LT3_QUIET: ") {
+        t.Errorf("got error %v, want one about $DMX_LT3_QUIET", err)
+    }
+}
+
+func TestLoaderMenu(t *testing.T) {
+    fname := writeConf(t, `FONT = top
+NORMAL_BG = #111
+NORMAL_FG = #222
+LINES = 5
+
+[lt4]
+NORMAL_BG = #333
+LINES = 10
+`)
+    t.Setenv("DMX_LINES", "20")
+    l := newTestLoader("lt4")
+    if err := l.Load([]string{ fname }); err != nil {
+        t.Fatalf("Load(): %v", err)
+    }
+    m := l.Menu
+    if m.Font != "top" || m.NormalFG != "#222" {
+        t.Errorf("top-level options not applied: FONT %q, NORMAL_FG %q", m.Font, m.NormalFG)
+    }
+    if m.NormalBG != "#333" {
+        t.Errorf("NORMAL_BG: got %q, want the [lt4] section's #333", m.NormalBG)
+    }
+    if m.MaxLines != 20 {
+        t.Errorf("LINES: got %d, want $DMX_LINES's 20", m.MaxLines)
+    }
+}
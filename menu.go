@@ -0,0 +1,343 @@
+// menu.go
+//
+// The Menu type, which holds everything about how menus are shown.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bufio"; "bytes"; "context"; "fmt"; "os"; "strconv" )
+
+// A Menu holds everything about how menus get shown: which Backend shows
+// them, and how they look. Different Menus can be configured differently
+// and used at the same time; a Menu is safe for concurrent use as long as
+// nobody is changing its fields.
+//
+// If Backend is nil, the first available Backend named in BackendName is
+// used; it can be a single name (see Backends), a comma-separated list of
+// them in order of preference, or "auto" (see ChooseBackend()). DmenuPath
+// is the executable to run for "dmenu" (if it isn't there, dmenu is looked
+// for in $PATH), and DmenuIndex says whether it has the -ix patch (see
+// Dmenu). Theme names the theme Autoconfigure() should apply (see
+// ApplyTheme()).
+//
+type Menu struct {
+    Backend     Backend
+    BackendName string
+    DmenuPath   string
+    DmenuIndex  bool
+    Theme       string
+    Style
+    conf        *confFile
+}
+
+// NewMenu() returns a Menu with the default settings.
+//
+func NewMenu() *Menu {
+    return &Menu{
+        BackendName: "auto",
+        DmenuPath:   "/usr/local/bin/dmenu",
+        Style: Style{
+            Font:       "-*-fixed-medium-r-normal--13-*-*-*-*-*-ISO10646-*", // "ProggyCleanTTCE-12"
+            NormalFG:   "#000",
+            NormalBG:   "#444",
+            SelectedFG: "#ddd",
+            SelectedBG: "#222",
+        },
+    }
+}
+
+// Default is the Menu used by the package-level functions like
+// DmenuSelect() and Autoconfigure().
+//
+var Default = NewMenu()
+
+// backend() returns the Backend m's menus should be shown with.
+//
+func (m *Menu) backend() (Backend, error) {
+    b, _, err := m.ChooseBackend()
+    return b, err
+}
+
+// An Option overrides one of a Menu's settings for a single call, for
+// example
+//
+//    dmx.DmenuSelect("file: ", files, dmx.WithCaseInsensitive(true))
+//
+type Option func(*Options)
+
+// WithStyle() replaces the whole Style.
+func WithStyle(s Style) Option { return func(o *Options) { o.Style = s } }
+
+// WithLines() sets the maximum number of lines shown (0 means no limit).
+func WithLines(n int) Option { return func(o *Options) { o.MaxLines = n } }
+
+// WithBottom() puts the menu at the bottom of the screen (or not).
+func WithBottom(b bool) Option { return func(o *Options) { o.Bottom = b } }
+
+// WithCaseInsensitive() turns case-insensitive matching on or off.
+func WithCaseInsensitive(b bool) Option {
+    return func(o *Options) { o.CaseInsensitive = b }
+}
+
+// WithMonitor() shows the menu on the given monitor.
+func WithMonitor(n int) Option {
+    return func(o *Options) { o.Monitor = strconv.Itoa(n) }
+}
+
+// WithWindow() embeds the menu into the window with the given id.
+func WithWindow(id string) Option { return func(o *Options) { o.WindowID = id } }
+
+// WithLineHeight() sets the height of each line, in pixels.
+func WithLineHeight(h int) Option { return func(o *Options) { o.LineHeight = h } }
+
+// WithDefault() highlights the given Item when the menu appears (if it's
+// one of the Items shown). See also Defaulter.
+func WithDefault(itm Item) Option { return func(o *Options) { o.dflt = itm } }
+
+// options() bundles up m's look, modified by opts, with the given prompt,
+// and works out how many of the n_items lines to show.
+//
+func (m *Menu) options(prompt string, n_items int, opts []Option) *Options {
+    o := &Options{ Prompt: prompt, Style: m.Style }
+    for _, opt := range opts {
+        opt(o)
+    }
+    o.Lines = n_items
+    if o.MaxLines > 0 && o.Lines > o.MaxLines {
+        o.Lines = o.MaxLines
+    }
+    return o
+}
+
+// Select() shows a menu of the supplied Items and returns the one chosen.
+// See DmenuSelect().
+//
+func (m *Menu) Select(prompt string, input ItemList, opts ...Option) (Item, error) {
+    return m.SelectContext(context.Background(), prompt, input, opts...)
+}
+
+// SelectContext() is like Select(), but gives up when ctx finishes. See
+// DmenuSelectContext().
+//
+func (m *Menu) SelectContext(ctx context.Context, prompt string, input ItemList, opts ...Option) (Item, error) {
+    res, err := m.ChooseContext(ctx, prompt, input, opts...)
+    if err != nil {
+        return nil, err
+    }
+    if res.Cancelled {
+        return nil, ErrCancelled
+    }
+    return res.Item, nil
+}
+
+// Choose() shows a menu of the supplied Items and reports what the user
+// did with it. See the package-level Choose().
+//
+func (m *Menu) Choose(prompt string, input ItemList, opts ...Option) (Result, error) {
+    return m.ChooseContext(context.Background(), prompt, input, opts...)
+}
+
+// ChooseContext() is like Choose(), but gives up when ctx finishes.
+//
+// Items' extras (see Iconer and friends) are passed along to Backends that
+// can show them, and left out otherwise. The default Item (see
+// WithDefault()) is highlighted if the Backend can do that (FeatSelect);
+// otherwise it's moved to the top of the menu.
+//
+// If the Backend can report the index of the chosen line (FeatIndex), that
+// is used to find the chosen Item. Otherwise, any duplicate menu lines are
+// made unique by tacking a number on the end (" [2]", " [3]", and so on),
+// so that every Item can still be chosen.
+//
+func (m *Menu) ChooseContext(ctx context.Context, prompt string, input ItemList, opts ...Option) (Result, error) {
+    results, err := m.choose(ctx, prompt, input, false, opts)
+    if err != nil {
+        return Result{}, err
+    }
+    return results[0], nil
+}
+
+// choose() does the work for ChooseContext() and ChooseMultiContext(). If
+// multi is set (and the Backend has FeatMulti), each line of the Backend's
+// output is a separate answer; otherwise, all of it is one. Either way,
+// there's at least one Result (which is the only one, if it's Cancelled).
+//
+func (m *Menu) choose(ctx context.Context, prompt string, input ItemList, multi bool, opts []Option) ([]Result, error) {
+    b, err := m.backend()
+    if err != nil {
+        return nil, err
+    }
+    o := m.options(prompt, len(input), opts)
+    o.Index = Supports(b, FeatIndex)
+    o.Multi = multi && Supports(b, FeatMulti)
+    
+    // order[i] is the index in input of the i-th menu line; it's only
+    // needed when the default Item has to be moved to the top.
+    var order []int
+    if d := defaultIndex(input, o.dflt); d > 0 {
+        if Supports(b, FeatSelect) {
+            o.Selected = d
+        } else {
+            order = make([]int, 0, len(input))
+            order = append(order, d)
+            for n := range input {
+                if n != d {
+                    order = append(order, n)
+                }
+            }
+        }
+    }
+    if fb, ok := b.(Featurer); ok && fb.Features() & FeatRows != 0 {
+        o.Rows = rowInfo(input)
+        if o.Rows != nil && order != nil {
+            rows := make([]RowInfo, len(order))
+            for i, n := range order {
+                rows[i] = o.Rows[n]
+            }
+            o.Rows = rows
+        }
+    }
+    
+    key_len := input.keyLen()
+    var table_lines [][]byte
+    if o.table != nil {
+        table_lines = o.table.menuLines(input, key_len)
+    }
+    var index *lineIndex
+    if !o.Index {
+        index = newLineIndex(len(input))
+    }
+    
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    feed := func(w *bufio.Writer) error {
+        for i := range input {
+            n := i
+            if order != nil {
+                n = order[i]
+            }
+            var ml []byte
+            if table_lines != nil {
+                ml = ensureReturnminated(table_lines[n])
+            } else {
+                ml = ensureReturnminated(input[n].MenuLine(key_len))
+            }
+            if index != nil {
+                var err error
+                if ml, err = index.add(ml, n); err != nil {
+                    cancel()
+                    return err
+                }
+            }
+            w.Write(ml)
+        }
+        return nil
+    }
+    r, wait, err := pipeLines(feed)
+    if err != nil {
+        return nil, err
+    }
+    stdout_bytes, err := b.Run(ctx, o, r)
+    if ferr := wait(); ferr != nil {
+        return nil, ferr
+    }
+    if err == ErrCancelled && o.Multi && len(stdout_bytes) > 0 {
+        // The lines chosen before the menu was dismissed still count.
+    } else if err == ErrCancelled {
+        return []Result{ { Cancelled: true } }, nil
+    } else if err != nil {
+        return nil, err
+    }
+    
+    outputs := [][]byte{ stdout_bytes }
+    if o.Multi && len(stdout_bytes) > 0 {
+        outputs = bytes.SplitAfter(bytes.TrimSuffix(stdout_bytes, CRLF), CRLF)
+    }
+    results := make([]Result, 0, len(outputs))
+    for _, output := range outputs {
+        if o.Index {
+            n, text, err := parseIndexed(output)
+            if err != nil {
+                return nil, err
+            } else if n >= len(input) {
+                return nil, fmt.Errorf("dmx: backend chose line %d of %d", n, len(input))
+            } else if n >= 0 {
+                if order != nil {
+                    n = order[n]
+                }
+                results = append(results, Result{ Item: input[n], n: n })
+            } else {
+                results = append(results, Result{ Typed: text })
+            }
+        } else if n, found := index.lookup(output); found {
+            results = append(results, Result{ Item: input[n], n: n })
+        } else {
+            results = append(results, Result{ Typed: string(bytes.TrimSuffix(output, CRLF)) })
+        }
+    }
+    return results, nil
+}
+
+// Run() passes the given lines (which should NOT be newline-terminated)
+// straight to the backend and returns its output. See the package-level
+// Run().
+//
+func (m *Menu) Run(prompt string, input [][]byte, opts ...Option) ([]byte, error) {
+    return m.RunContext(context.Background(), prompt, input, opts...)
+}
+
+// RunContext() is like Run(), but gives up when ctx finishes.
+//
+func (m *Menu) RunContext(ctx context.Context, prompt string, input [][]byte, opts ...Option) ([]byte, error) {
+    b, err := m.backend()
+    if err != nil {
+        return nil, err
+    }
+    
+    r, wait, err := pipeLines(func(w *bufio.Writer) error {
+        for _, bs := range input {
+            w.Write(bs)
+            w.Write(CRLF)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    out, err := b.Run(ctx, m.options(prompt, len(input), opts), r)
+    wait()
+    return out, err
+}
+
+// pipeLines() runs feed in another goroutine, writing into a pipe, and
+// returns the reading end for a Backend to read the menu lines from. That
+// way the menu program can start while the lines are still being written,
+// and (since it's an *os.File) gets the pipe as its stdin directly, rather
+// than through yet another copy.
+//
+// feed can ignore errors writing to w: they only mean the menu program has
+// stopped reading, and w just discards everything after one. Anything feed
+// itself returns is what wait() returns. wait() must be called once the
+// Backend is done with the pipe; it closes it, and waits for feed to
+// finish.
+//
+func pipeLines(feed func(w *bufio.Writer) error) (*os.File, func() error, error) {
+    r, w, err := os.Pipe()
+    if err != nil {
+        return nil, nil, err
+    }
+    done := make(chan error, 1)
+    go func() {
+        bw := bufio.NewWriterSize(w, 64*1024)
+        err := feed(bw)
+        bw.Flush()
+        w.Close()
+        done <- err
+    }()
+    wait := func() error {
+        r.Close()
+        return <-done
+    }
+    return r, wait, nil
+}
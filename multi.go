@@ -0,0 +1,95 @@
+// multi.go
+//
+// Menus from which several Items can be chosen at once.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "context" )
+
+// ChooseMulti() shows a menu from which several Items can be chosen, and
+// reports each thing the user did with it. See the package-level
+// ChooseMulti().
+//
+func (m *Menu) ChooseMulti(prompt string, input ItemList, opts ...Option) ([]Result, error) {
+    return m.ChooseMultiContext(context.Background(), prompt, input, opts...)
+}
+
+// ChooseMultiContext() is like ChooseMulti(), but gives up when ctx
+// finishes.
+//
+func (m *Menu) ChooseMultiContext(ctx context.Context, prompt string, input ItemList, opts ...Option) ([]Result, error) {
+    return m.choose(ctx, prompt, input, true, opts)
+}
+
+// SelectMulti() shows a menu of the supplied Items and returns the ones
+// chosen. See DmenuSelectMulti().
+//
+func (m *Menu) SelectMulti(prompt string, input ItemList, opts ...Option) ([]Item, error) {
+    return m.SelectMultiContext(context.Background(), prompt, input, opts...)
+}
+
+// SelectMultiContext() is like SelectMulti(), but gives up when ctx
+// finishes.
+//
+func (m *Menu) SelectMultiContext(ctx context.Context, prompt string, input ItemList, opts ...Option) ([]Item, error) {
+    results, err := m.ChooseMultiContext(ctx, prompt, input, opts...)
+    if err != nil {
+        return nil, err
+    }
+    if results[0].Cancelled {
+        return nil, ErrCancelled
+    }
+    
+    chosen := make([]Item, 0, len(results))
+    seen := make(map[int]bool, len(results))
+    for _, res := range results {
+        if res.Item == nil || seen[res.n] {
+            continue
+        }
+        seen[res.n] = true
+        chosen = append(chosen, res.Item)
+    }
+    return chosen, nil
+}
+
+// DmenuSelectMulti() is like DmenuSelect(), but lets the user choose
+// several Items, and returns them in the order they were chosen. With
+// dmenu, Ctrl-Enter chooses the highlighted line and keeps the menu open;
+// with rofi, Shift-Enter does; with fzf, Tab marks lines to be chosen
+// together. Backends that can't do that (the ones that don't report
+// FeatMulti) only let one Item be chosen.
+//
+// Anything typed that doesn't match an Item is left out, as is any Item
+// chosen more than once after the first time, so if nothing matches, the
+// slice is empty (and the error is nil). Dismissing the menu after
+// choosing some Items (with Ctrl-Enter, say, and then Escape) still
+// returns those Items; only if none were chosen is the error ErrCancelled.
+//
+func DmenuSelectMulti(prompt string, input ItemList, opts ...Option) ([]Item, error) {
+    return Default.SelectMulti(prompt, input, opts...)
+}
+
+// DmenuSelectMultiContext() is to DmenuSelectMulti() as
+// DmenuSelectContext() is to DmenuSelect().
+//
+func DmenuSelectMultiContext(ctx context.Context, prompt string, input ItemList, opts ...Option) ([]Item, error) {
+    return Default.SelectMultiContext(ctx, prompt, input, opts...)
+}
+
+// ChooseMulti() is to DmenuSelectMulti() as Choose() is to DmenuSelect():
+// it returns a Result for each line the user chose or typed, in order. If
+// the user dismisses the menu without choosing anything, there's just the
+// one Result, and it's Cancelled.
+//
+func ChooseMulti(prompt string, input ItemList, opts ...Option) ([]Result, error) {
+    return Default.ChooseMulti(prompt, input, opts...)
+}
+
+// ChooseMultiContext() is to ChooseMulti() as DmenuSelectContext() is to
+// DmenuSelect().
+//
+func ChooseMultiContext(ctx context.Context, prompt string, input ItemList, opts ...Option) ([]Result, error) {
+    return Default.ChooseMultiContext(ctx, prompt, input, opts...)
+}
@@ -0,0 +1,110 @@
+// rows.go
+//
+// Optional extras an Item can ask for, for backends that can show them.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "reflect" )
+
+// An Iconer is an Item with an icon: either the name of one from the icon
+// theme (like "folder" or "text-x-generic") or the path to an image file.
+//
+type Iconer interface {
+    Icon() string
+}
+
+// An Urgenter is an Item that may want to be drawn as urgent (rofi draws
+// these in its "urgent" colors).
+//
+type Urgenter interface {
+    Urgent() bool
+}
+
+// An Activer is an Item that may want to be drawn as active (rofi draws
+// these in its "active" colors).
+//
+type Activer interface {
+    Active() bool
+}
+
+// A SearchTexter is an Item with extra text that the user's typing should
+// match, but that isn't shown (tags, say, or a file's full path).
+//
+type SearchTexter interface {
+    SearchText() string
+}
+
+// A Defaulter is an Item that may be the one that should be highlighted
+// when the menu appears (see WithDefault(), which takes precedence). The
+// first Item whose IsDefault() returns true is.
+//
+type Defaulter interface {
+    IsDefault() bool
+}
+
+// RowInfo holds the extras for one line of a menu. See Options.Rows.
+//
+type RowInfo struct {
+    Icon       string
+    Urgent     bool
+    Active     bool
+    SearchText string
+}
+
+// FeatRows is the set of Features that have to do with RowInfo.
+//
+const FeatRows = FeatIcon | FeatUrgent | FeatActive | FeatSearchText
+
+// rowInfo() gathers the extras for each of the Items in input, or returns
+// nil if none of them have any.
+//
+func rowInfo(input ItemList) []RowInfo {
+    var rows []RowInfo
+    for n, itm := range input {
+        var ri RowInfo
+        if x, ok := itm.(Iconer); ok {
+            ri.Icon = x.Icon()
+        }
+        if x, ok := itm.(Urgenter); ok {
+            ri.Urgent = x.Urgent()
+        }
+        if x, ok := itm.(Activer); ok {
+            ri.Active = x.Active()
+        }
+        if x, ok := itm.(SearchTexter); ok {
+            ri.SearchText = x.SearchText()
+        }
+        if ri == (RowInfo{}) {
+            continue
+        }
+        if rows == nil {
+            rows = make([]RowInfo, len(input))
+        }
+        rows[n] = ri
+    }
+    return rows
+}
+
+// defaultIndex() returns the index of the Item in input that should be
+// highlighted first: dflt, if it's there, or else the first Defaulter that
+// says it is. It returns -1 if there isn't one.
+//
+func defaultIndex(input ItemList, dflt Item) int {
+    if dflt != nil && reflect.TypeOf(dflt).Comparable() {
+        for n, itm := range input {
+            // Only compare Items of the same type, so that comparing two
+            // incomparable ones can't panic.
+            if reflect.TypeOf(itm) == reflect.TypeOf(dflt) && itm == dflt {
+                return n
+            }
+        }
+    }
+    for n, itm := range input {
+        if d, ok := itm.(Defaulter); ok && d.IsDefault() {
+            return n
+        }
+    }
+    return -1
+}
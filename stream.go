@@ -0,0 +1,166 @@
+// stream.go
+//
+// Menus whose Items are still arriving while the menu is up.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "context"; "fmt"; "os"; "sync" )
+
+// streamLines is how tall a streamed menu is if MaxLines isn't set, since
+// there's no telling how many Items there will be.
+//
+const streamLines = 20
+
+// WithKeyWidth() sets the key width passed to MenuLine() for a streamed
+// menu (see DmenuSelectStream()), whose keys can't all be measured ahead
+// of time. It has no effect on other menus.
+//
+func WithKeyWidth(w int) Option { return func(o *Options) { o.keyWidth = w } }
+
+// A streamer feeds Items from a channel to a Backend as they arrive, and
+// keeps track of which line is which.
+//
+type streamer struct {
+    mu       sync.Mutex
+    items    ItemList
+    index    *lineIndex     // nil if the Backend reports indices
+    err      error          // the first thing that went wrong writing
+    stop     chan struct{}
+}
+
+// feed() writes the MenuLine() of each Item from src to w, until src is
+// closed or s.stop is, and then closes w. Either way it keeps reading (and
+// discarding) from src until it's closed, so whoever is sending never gets
+// stuck.
+//
+func (s *streamer) feed(src <-chan Item, w *os.File, key_len int) {
+    defer func() {
+        w.Close()
+        for range src {
+        }
+    }()
+    for {
+        var itm Item
+        var ok bool
+        select {
+            case itm, ok = <-src:
+            case <-s.stop:
+                return
+        }
+        if !ok {
+            return
+        }
+    
+        ml := ensureReturnminated(itm.MenuLine(key_len))
+        s.mu.Lock()
+        var err error
+        if s.index != nil {
+            ml, err = s.index.add(ml, len(s.items))
+        }
+        if err == nil {
+            s.items = append(s.items, itm)
+        } else if s.err == nil {
+            s.err = err
+        }
+        s.mu.Unlock()
+        if err != nil {
+            return
+        }
+        if _, err := w.Write(ml); err != nil {
+            return      // the Backend has stopped reading
+        }
+    }
+}
+
+// SelectStream() is like Select(), but shows the Items as they arrive from
+// items. See DmenuSelectStream().
+//
+func (m *Menu) SelectStream(prompt string, items <-chan Item, opts ...Option) (Item, error) {
+    return m.SelectStreamContext(context.Background(), prompt, items, opts...)
+}
+
+// SelectStreamContext() is like SelectStream(), but gives up when ctx
+// finishes.
+//
+func (m *Menu) SelectStreamContext(ctx context.Context, prompt string, items <-chan Item, opts ...Option) (Item, error) {
+    b, err := m.backend()
+    if err != nil {
+        return nil, err
+    }
+    o := m.options(prompt, 0, opts)
+    o.Lines = o.MaxLines
+    if o.Lines <= 0 {
+        o.Lines = streamLines
+    }
+    o.Index = Supports(b, FeatIndex)
+    
+    s := &streamer{ stop: make(chan struct{}) }
+    if !o.Index {
+        s.index = newLineIndex(0)
+    }
+    // The menu program gets the pipe as its stdin directly (see
+    // pipeLines()). With an io.Pipe, the exec package would copy into it
+    // from another goroutine, and wouldn't let the program be waited for
+    // until that copy finished: that is, until the next Item arrived.
+    pr, pw, err := os.Pipe()
+    if err != nil {
+        return nil, err
+    }
+    go s.feed(items, pw, o.keyWidth)
+    
+    stdout_bytes, err := b.Run(ctx, o, pr)
+    close(s.stop)
+    pr.Close()
+    
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.err != nil {
+        return nil, s.err
+    } else if err != nil {
+        return nil, err
+    }
+    
+    if o.Index {
+        n, _, err := parseIndexed(stdout_bytes)
+        if err != nil {
+            return nil, err
+        } else if n >= len(s.items) {
+            return nil, fmt.Errorf("dmx: backend chose line %d of %d", n, len(s.items))
+        } else if n >= 0 {
+            return s.items[n], nil
+        }
+        return nil, nil
+    }
+    
+    if n, found := s.index.lookup(stdout_bytes); found {
+        return s.items[n], nil
+    }
+    return nil, nil
+}
+
+// DmenuSelectStream() is like DmenuSelect(), but takes its Items from a
+// channel, and starts the menu right away instead of waiting for all of
+// them; each one is added to the menu as it arrives. Use it when the Items
+// take a while to find (walking a directory tree, say).
+//
+// Since the Items aren't all known ahead of time, a streamed menu can't be
+// laid out as well as an ordinary one: MenuLine() is passed the width set
+// WithKeyWidth() (or 0) instead of the widest key, WithTable() and
+// WithDefault() are ignored, as are the Items' extras (see Iconer), and
+// the menu is MaxLines tall (or 20 lines, if that isn't set).
+//
+// Once the menu is closed, the rest of the Items are read from items and
+// thrown away, so the sender must close items when it's done.
+//
+func DmenuSelectStream(prompt string, items <-chan Item, opts ...Option) (Item, error) {
+    return Default.SelectStream(prompt, items, opts...)
+}
+
+// DmenuSelectStreamContext() is to DmenuSelectStream() as
+// DmenuSelectContext() is to DmenuSelect().
+//
+func DmenuSelectStreamContext(ctx context.Context, prompt string, items <-chan Item, opts ...Option) (Item, error) {
+    return Default.SelectStreamContext(ctx, prompt, items, opts...)
+}
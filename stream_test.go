@@ -0,0 +1,59 @@
+// stream_test.go
+//
+// Tests for streamed menus.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "context"; "os"; "path/filepath"; "testing"; "time" )
+
+// fakeDmenu() writes a shell script that stands in for dmenu: it reads
+// just the first menu line and prints it, as if the user picked it as
+// soon as it appeared.
+//
+func fakeDmenu(t *testing.T) string {
+    path := filepath.Join(t.TempDir(), "dmenu")
+    script := "#!/bin/sh\nread line\nprintf '%s\\n' \"$line\"\n"
+    if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+// The menu has to be able to finish while the sender is still busy, and
+// not just when it sends another Item or closes the channel.
+//
+func TestSelectStreamIdleSender(t *testing.T) {
+    m := NewMenu()
+    m.Backend = &Dmenu{ Path: fakeDmenu(t) }
+    
+    first := &benchItem{ key: "first", desc: "the only one sent" }
+    items := make(chan Item)
+    go func() {
+        items <- first
+        // ...and then nothing more, and the channel is never closed.
+    }()
+    
+    type answer struct {
+        itm Item
+        err error
+    }
+    ch := make(chan answer, 1)
+    go func() {
+        itm, err := m.SelectStreamContext(context.Background(), "> ", items)
+        ch <- answer{ itm, err }
+    }()
+    
+    select {
+        case a := <-ch:
+            if a.err != nil {
+                t.Fatal(a.err)
+            }
+            if a.itm != Item(first) {
+                t.Errorf("chose %v, expected %v", a.itm, first)
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatal("SelectStream() still waiting for the sender")
+    }
+}
@@ -0,0 +1,165 @@
+// table.go
+//
+// Laying out menu lines in columns.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "strings" )
+
+// A Celler is an Item that can be shown as a row of a table: Cells()
+// returns the text of each column. When a menu is shown WithTable(), the
+// library works out how wide each column needs to be and builds the menu
+// lines itself, instead of calling MenuLine().
+//
+type Celler interface {
+    Cells() []string
+}
+
+// Align says which side of its column a cell's text sticks to.
+//
+type Align int
+
+const(
+    AlignLeft Align = iota
+    AlignRight
+)
+
+// Column describes one column of a Table. If Max is more than zero, cells
+// wider than that are cut short with an ellipsis.
+//
+type Column struct {
+    Max   int
+    Align Align
+}
+
+// A Table lays out rows of cells in aligned columns. Rows with more cells
+// than there are Columns get left-aligned, unlimited columns for the
+// extras. Sep goes between columns; if it's empty, two spaces are used.
+//
+type Table struct {
+    Columns []Column
+    Sep     string
+}
+
+// Ellipsis is what Truncate() puts at the end of strings it shortens.
+//
+var Ellipsis = "…"
+
+// Truncate() shortens s, if it's wider than width columns, so that it
+// fits, ending it with Ellipsis (or just dots, if even that won't fit). If
+// width isn't positive, the result is "". It only cuts between whole
+// characters (as Width() sees them), so it never leaves half a flag, or an
+// emoji without the rest of the sequence it was joined to.
+//
+func Truncate(s string, width int) string {
+    if width <= 0 {
+        return ""
+    }
+    if Width(s) <= width {
+        return s
+    }
+    ew := Width(Ellipsis)
+    if width < ew {
+        return strings.Repeat(".", width)
+    }
+    w, end := 0, len(s)
+    var ws widthState
+    for n, r := range s {
+        rw, starts := ws.step(r)
+        if starts && w + rw > width - ew {
+            end = n
+            break
+        }
+        w += rw
+    }
+    return s[:end] + Ellipsis
+}
+
+func (t *Table) column(n int) Column {
+    if n < len(t.Columns) {
+        return t.Columns[n]
+    }
+    return Column{}
+}
+
+// Format() lays out the given rows and returns one line (without a
+// newline) for each.
+//
+func (t *Table) Format(rows [][]string) [][]byte {
+    sep := t.Sep
+    if sep == "" {
+        sep = "  "
+    }
+    
+    widths := make([]int, 0)
+    cells := make([][]string, len(rows))
+    for r, row := range rows {
+        cells[r] = make([]string, len(row))
+        for c, cell := range row {
+            if col := t.column(c); col.Max > 0 {
+                cell = Truncate(cell, col.Max)
+            }
+            cells[r][c] = cell
+            for len(widths) <= c {
+                widths = append(widths, 0)
+            }
+            if w := Width(cell); w > widths[c] {
+                widths[c] = w
+            }
+        }
+    }
+    
+    lines := make([][]byte, len(rows))
+    var b strings.Builder
+    for r, row := range cells {
+        b.Reset()
+        for c, cell := range row {
+            if c > 0 {
+                b.WriteString(sep)
+            }
+            last := c == len(row) - 1
+            switch {
+                case t.column(c).Align == AlignRight:
+                    b.WriteString(PadLeft(cell, widths[c]))
+                case last:
+                    // no trailing spaces
+                    b.WriteString(cell)
+                default:
+                    b.WriteString(Pad(cell, widths[c]))
+            }
+        }
+        lines[r] = []byte(b.String())
+    }
+    return lines
+}
+
+// menuLines() makes the menu lines for input: Items that are Cellers are
+// laid out by the Table, and any others just use their MenuLine().
+//
+func (t *Table) menuLines(input ItemList, key_len int) [][]byte {
+    rows := make([][]string, 0, len(input))
+    for _, itm := range input {
+        if c, ok := itm.(Celler); ok {
+            rows = append(rows, c.Cells())
+        }
+    }
+    formatted := t.Format(rows)
+    
+    lines := make([][]byte, len(input))
+    n := 0
+    for i, itm := range input {
+        if _, ok := itm.(Celler); ok {
+            lines[i] = formatted[n]
+            n++
+        } else {
+            lines[i] = itm.MenuLine(key_len)
+        }
+    }
+    return lines
+}
+
+// WithTable() shows the menu's Items as rows of t (see Celler).
+//
+func WithTable(t *Table) Option { return func(o *Options) { o.table = t } }
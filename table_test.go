@@ -0,0 +1,111 @@
+// table_test.go
+//
+// Tests for Truncate() and Table.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "testing" )
+
+func TestTruncate(t *testing.T) {
+    tests := []struct {
+        s     string
+        width int
+        want  string
+    }{
+        { "hello", 10, "hello" },
+        { "hello", 5, "hello" },
+        { "hello", 4, "hel…" },
+        { "hello", 2, "h…" },
+        { "hello", 1, "…" },
+        { "hello", 0, "" },
+        { "hello", -1, "" },
+        { "", 0, "" },
+        { "漢字漢字", 6, "漢字…" },
+        { "漢字漢字", 4, "漢…" },       // 漢字 would be 5 with the …
+        { "🇺🇸xxxx", 3, "🇺🇸…" },
+        { "🇺🇸xxxx", 2, "…" },           // not half a flag
+        { "👨\u200d👩\u200d👧xxxx", 3, "👨\u200d👩\u200d👧…" },
+        { "👨\u200d👩\u200d👧xxxx", 2, "…" },
+        { "👍🏽👍🏽xx", 4, "👍🏽…" },
+        { "cafe\u0301s", 5, "cafe\u0301s" },
+        { "cafe\u0301ss", 5, "cafe\u0301…" },     // the accent stays with its e
+    }
+    for _, tc := range tests {
+        got := Truncate(tc.s, tc.width)
+        if got != tc.want {
+            t.Errorf("Truncate(%q, %d) = %q, expected %q", tc.s, tc.width, got, tc.want)
+        }
+        if tc.width > 0 && Width(got) > tc.width {
+            t.Errorf("Truncate(%q, %d) = %q, which is %d wide", tc.s, tc.width, got, Width(got))
+        }
+    }
+}
+
+// An Ellipsis wider than the space left means dots instead.
+//
+func TestTruncateWideEllipsis(t *testing.T) {
+    defer func(e string) { Ellipsis = e }(Ellipsis)
+    Ellipsis = "..."
+    for width, want := range map[int]string{ 1: ".", 2: "..", 3: "...", 4: "h...", 5: "hello" } {
+        if got := Truncate("hello", width); got != want {
+            t.Errorf("Truncate(\"hello\", %d) = %q, expected %q", width, got, want)
+        }
+    }
+}
+
+func TestTableFormat(t *testing.T) {
+    tests := []struct {
+        name  string
+        table Table
+        rows  [][]string
+        want  []string
+    }{
+        { "left aligned",
+          Table{},
+          [][]string{ { "a", "one" }, { "bbb", "two" } },
+          []string{ "a    one", "bbb  two" } },
+        { "no trailing padding",
+          Table{},
+          [][]string{ { "a", "x" }, { "b", "longer" } },
+          []string{ "a  x", "b  longer" } },
+        { "right aligned",
+          Table{ Columns: []Column{ {}, { Align: AlignRight } } },
+          [][]string{ { "a", "1" }, { "b", "100" } },
+          []string{ "a    1", "b  100" } },
+        { "right aligned last column is padded",
+          Table{ Columns: []Column{ { Align: AlignRight }, { Align: AlignRight } } },
+          [][]string{ { "1", "x" }, { "22", "yyy" } },
+          []string{ " 1    x", "22  yyy" } },
+        { "separator",
+          Table{ Sep: " | " },
+          [][]string{ { "a", "b" }, { "cc", "d" } },
+          []string{ "a  | b", "cc | d" } },
+        { "max width",
+          Table{ Columns: []Column{ { Max: 4 } } },
+          [][]string{ { "abcdefg", "x" }, { "ab", "y" } },
+          []string{ "abc…  x", "ab    y" } },
+        { "wide characters",
+          Table{},
+          [][]string{ { "漢字", "x" }, { "ab", "y" }, { "🇺🇸", "z" } },
+          []string{ "漢字  x", "ab    y", "🇺🇸    z" } },
+        { "ragged rows",
+          Table{},
+          [][]string{ { "a", "b", "c" }, { "dd" } },
+          []string{ "a   b  c", "dd" } },
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            got := tc.table.Format(tc.rows)
+            if len(got) != len(tc.want) {
+                t.Fatalf("%d lines, expected %d", len(got), len(tc.want))
+            }
+            for n := range got {
+                if string(got[n]) != tc.want[n] {
+                    t.Errorf("line %d is %q, expected %q", n, got[n], tc.want[n])
+                }
+            }
+        })
+    }
+}
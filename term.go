@@ -0,0 +1,451 @@
+// term.go
+//
+// A menu drawn right on the terminal, for when there's no display.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "bufio"; "context"; "fmt"; "io"; "os"; "os/exec"; "strconv"
+        "strings"; "unicode/utf8"
+)
+
+// Term draws the menu itself, full-screen on the terminal, so it works
+// where there's no X or Wayland display to put dmenu on (over ssh, say, or
+// on a console). It's what a Menu uses when there's no display and none of
+// its backends will do without one (see Menu.ChooseBackend()).
+//
+// TTY is the terminal to use; if it's empty, it's /dev/tty (the process's
+// controlling terminal), so the menu still works when stdin and stdout
+// are redirected. The terminal is switched into raw mode with stty(1)
+// while the menu is up.
+//
+// The menu comes up right away, and lines are added to it as they're
+// read, so streamed menus (see DmenuSelectStream()) work as they should.
+//
+// Typing narrows the list to the lines that contain every word typed
+// (lines that match the whole thing exactly, then ones that start with it,
+// come first). Up and Down (or Ctrl-P and Ctrl-N), Page Up, Page Down,
+// Home and End move the highlight; Tab copies the highlighted line into
+// the input; Enter chooses the highlighted line, or the typed text if
+// nothing matches; Alt-Enter chooses the typed text regardless. Escape,
+// Ctrl-C and Ctrl-G dismiss the menu. When asking for a password, what's
+// typed isn't shown at all.
+//
+// Colors given as #rgb or #rrggbb are drawn in 24-bit color; other color
+// names are ignored, and if there are no selected colors, the highlighted
+// line is drawn in reverse video. The font, monitor, window and line
+// height mean nothing to a terminal.
+//
+type Term struct {
+    TTY string
+}
+
+func (b *Term) Features() Feature { return FeatIndex | FeatSelect | FeatPassword }
+
+func (b *Term) Run(ctx context.Context, opts *Options, input io.Reader) ([]byte, error) {
+    tty, err := os.OpenFile(orDefault(b.TTY, "/dev/tty"), os.O_RDWR, 0)
+    if err != nil {
+        return nil, fmt.Errorf("dmx: no terminal: %w", err)
+    }
+    defer tty.Close()
+    
+    saved, err := stty(tty, "-g")
+    if err != nil {
+        return nil, err
+    }
+    if _, err := stty(tty, "raw", "-echo"); err != nil {
+        return nil, err
+    }
+    tm := newTermMenu(opts)
+    tm.rows, tm.cols = termSize(tty)
+    tty.WriteString("\x1b[?1049h")       // switch to the alternate screen
+    defer func() {
+        tty.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l")
+        stty(tty, strings.TrimSpace(string(saved)))
+    }()
+    
+    keys := make(chan []byte)
+    done := make(chan struct{})
+    defer close(done)
+    var read_err error
+    arrived := readLines(input, done, &read_err)
+    go func() {
+        for {
+            buf := make([]byte, 256)
+            n, err := tty.Read(buf)
+            if err != nil {
+                return
+            }
+            select {
+                case keys <- buf[:n]:
+                case <-done:
+                    return
+            }
+        }
+    }()
+    
+    out := bufio.NewWriter(tty)
+    for {
+        tm.draw(out)
+        out.Flush()
+        var buf []byte
+        select {
+            case buf = <-keys:
+            case lines, ok := <-arrived:
+                if !ok {
+                    if read_err != nil {
+                        return nil, read_err
+                    }
+                    arrived = nil
+                }
+                tm.add(lines)
+                continue
+            case <-ctx.Done():
+                return nil, ctx.Err()
+        }
+        for len(buf) > 0 {
+            key, n := decodeKey(buf)
+            buf = buf[n:]
+            if res, err := tm.key(key); res != nil || err != nil {
+                return res, err
+            }
+        }
+    }
+}
+
+// readLines() reads newline-terminated lines from input in another
+// goroutine, and sends them (without their newlines) on the channel it
+// returns, a batch at a time: whatever has arrived, up to termBatch lines.
+// The channel is closed at the end of input, or after setting *err if
+// reading fails. If done is closed first, it gives up.
+//
+func readLines(input io.Reader, done <-chan struct{}, err *error) <-chan []string {
+    ch := make(chan []string)
+    go func() {
+        defer close(ch)
+        rdr := bufio.NewReader(input)
+        batch := make([]string, 0)
+        for {
+            line, rerr := rdr.ReadString('\n')
+            if len(line) > 0 {
+                batch = append(batch, strings.TrimRight(line, "\r\n"))
+            }
+            if rerr != nil && rerr != io.EOF {
+                *err = rerr
+                return
+            }
+            if len(batch) > 0 && (rerr != nil || rdr.Buffered() == 0 || len(batch) >= termBatch) {
+                select {
+                    case ch <- batch:
+                    case <-done:
+                        return
+                }
+                batch = make([]string, 0)
+            }
+            if rerr != nil {
+                return
+            }
+        }
+    }()
+    return ch
+}
+
+// termBatch is the most lines readLines() sends at once, so a big menu
+// gets drawn before it's all been read.
+//
+const termBatch = 1024
+
+// stty() runs stty(1) with the given arguments on tty and returns what it
+// prints.
+//
+func stty(tty *os.File, args ...string) ([]byte, error) {
+    cmd := exec.Command("stty", args...)
+    cmd.Stdin = tty
+    out, err := cmd.Output()
+    if err != nil {
+        return nil, fmt.Errorf("dmx: stty %s: %w", strings.Join(args, " "), err)
+    }
+    return out, nil
+}
+
+// termSize() returns the number of rows and columns of tty, or 24 and 80
+// if it can't tell.
+//
+func termSize(tty *os.File) (int, int) {
+    out, err := stty(tty, "size")
+    if err == nil {
+        f := strings.Fields(string(out))
+        if len(f) == 2 {
+            rows, rerr := strconv.Atoi(f[0])
+            cols, cerr := strconv.Atoi(f[1])
+            if rerr == nil && cerr == nil && rows > 1 && cols > 0 {
+                return rows, cols
+            }
+        }
+    }
+    return 24, 80
+}
+
+// decodeKey() returns the first keypress in buf and how many bytes of it
+// that took. A keypress is a single character, an escape sequence like
+// "\x1b[A" (Up), "\x1b" followed by a character (that character with Alt
+// held down), or "\x1b" by itself (Escape).
+//
+func decodeKey(buf []byte) (string, int) {
+    if buf[0] != 0x1b || len(buf) == 1 {
+        _, n := utf8.DecodeRune(buf)
+        return string(buf[:n]), n
+    }
+    if buf[1] == '[' || buf[1] == 'O' {
+        for n := 2; n < len(buf); n++ {
+            if buf[n] >= 0x40 && buf[n] <= 0x7e {
+                return string(buf[:n+1]), n+1
+            }
+        }
+        return string(buf), len(buf)
+    }
+    _, n := utf8.DecodeRune(buf[1:])
+    return string(buf[:n+1]), n+1
+}
+
+// A termMenu is the state of a Term menu.
+//
+type termMenu struct {
+    opts    *Options
+    lines   []string
+    folded  []string        // lines in lower case, if CaseInsensitive
+    query   string
+    matches []int           // indices in lines of the lines shown
+    cur     int             // index in matches of the highlighted line
+    top     int             // index in matches of the first line shown
+    dflt    int             // line to highlight once it arrives, or -1
+    rows    int
+    cols    int
+}
+
+func newTermMenu(opts *Options) *termMenu {
+    tm := &termMenu{ opts: opts, dflt: -1 }
+    if opts.Selected > 0 {
+        tm.dflt = opts.Selected
+    }
+    return tm
+}
+
+// add() adds lines that have just been read to the menu, leaving the
+// highlight on the line it was on.
+//
+func (tm *termMenu) add(lines []string) {
+    tm.lines = append(tm.lines, lines...)
+    if tm.opts.CaseInsensitive {
+        for _, line := range lines {
+            tm.folded = append(tm.folded, strings.ToLower(line))
+        }
+    } else {
+        tm.folded = tm.lines
+    }
+    
+    cur_line := -1
+    if len(tm.matches) > 0 {
+        cur_line = tm.matches[tm.cur]
+    }
+    if tm.dflt >= 0 && tm.dflt < len(tm.lines) && tm.query == "" {
+        cur_line, tm.dflt = tm.dflt, -1
+    }
+    top := tm.top
+    tm.filter()
+    for i, n := range tm.matches {
+        if n == cur_line {
+            tm.cur, tm.top = i, top
+            break
+        }
+    }
+}
+
+// filter() works out which lines match the query, and puts the highlight
+// back on the first of them.
+//
+func (tm *termMenu) filter() {
+    tm.cur, tm.top = 0, 0
+    tm.matches = tm.matches[:0]
+    if tm.query == "" {
+        for n := range tm.lines {
+            tm.matches = append(tm.matches, n)
+        }
+        return
+    }
+    query := tm.query
+    if tm.opts.CaseInsensitive {
+        query = strings.ToLower(query)
+    }
+    words := strings.Fields(query)
+    var prefix, rest []int
+    for n, line := range tm.folded {
+        matched := true
+        for _, w := range words {
+            if !strings.Contains(line, w) {
+                matched = false
+                break
+            }
+        }
+        switch {
+            case !matched:
+            case line == query:
+                tm.matches = append(tm.matches, n)
+            case strings.HasPrefix(line, query):
+                prefix = append(prefix, n)
+            default:
+                rest = append(rest, n)
+        }
+    }
+    tm.matches = append(tm.matches, prefix...)
+    tm.matches = append(tm.matches, rest...)
+}
+
+// height() is the number of menu lines that fit under (or over) the
+// prompt.
+//
+func (tm *termMenu) height() int {
+    h := tm.opts.Lines
+    if h <= 0 || h > tm.rows - 1 {
+        h = tm.rows - 1
+    }
+    return h
+}
+
+// move() moves the highlight by d lines, and scrolls to keep it in view.
+//
+func (tm *termMenu) move(d int) {
+    tm.cur += d
+    if tm.cur >= len(tm.matches) {
+        tm.cur = len(tm.matches) - 1
+    }
+    if tm.cur < 0 {
+        tm.cur = 0
+    }
+}
+
+// key() responds to a keypress. Once the menu is done, it returns what
+// Run() should.
+//
+func (tm *termMenu) key(key string) ([]byte, error) {
+    tm.dflt = -1        // the user has taken over
+    switch key {
+        case "\x1b", "\x03", "\x07":
+            return nil, ErrCancelled
+        case "\r", "\n":
+            if len(tm.matches) > 0 {
+                return tm.result(tm.matches[tm.cur]), nil
+            }
+            return tm.result(-1), nil
+        case "\x1b\r":
+            return tm.result(-1), nil
+        case "\t":
+            if len(tm.matches) > 0 {
+                tm.query = tm.lines[tm.matches[tm.cur]]
+                tm.filter()
+            }
+        case "\x7f", "\b":
+            if tm.query != "" {
+                _, n := utf8.DecodeLastRuneInString(tm.query)
+                tm.query = tm.query[:len(tm.query)-n]
+                tm.filter()
+            }
+        case "\x15":
+            tm.query = ""
+            tm.filter()
+        case "\x1b[A", "\x1bOA", "\x10":
+            tm.move(-1)
+        case "\x1b[B", "\x1bOB", "\x0e":
+            tm.move(1)
+        case "\x1b[5~":
+            tm.move(-tm.height())
+        case "\x1b[6~":
+            tm.move(tm.height())
+        case "\x1b[H", "\x1bOH", "\x1b[1~":
+            tm.move(-len(tm.matches))
+        case "\x1b[F", "\x1bOF", "\x1b[4~":
+            tm.move(len(tm.matches))
+        default:
+            // Anything else that isn't a control character gets typed.
+            if r, _ := utf8.DecodeRuneInString(key); r >= ' ' && r != 0x7f {
+                tm.query += key
+                tm.filter()
+            }
+    }
+    return nil, nil
+}
+
+// result() is Run()'s output for choosing line n (or the typed text, if n
+// is -1).
+//
+func (tm *termMenu) result(n int) []byte {
+    text := tm.query
+    if n >= 0 {
+        text = tm.lines[n]
+    }
+    if tm.opts.Index {
+        return []byte(fmt.Sprintf("%d %s\n", n, text))
+    }
+    return []byte(text + "\n")
+}
+
+// draw() draws the prompt and the visible part of the list on w.
+//
+func (tm *termMenu) draw(w *bufio.Writer) {
+    h := tm.height()
+    if tm.cur < tm.top {
+        tm.top = tm.cur
+    } else if tm.cur >= tm.top + h {
+        tm.top = tm.cur - h + 1
+    }
+    
+    normal := termColor(tm.opts.NormalFG, false) + termColor(tm.opts.NormalBG, true)
+    selected := termColor(tm.opts.SelectedFG, false) + termColor(tm.opts.SelectedBG, true)
+    if selected == "" {
+        selected = "\x1b[7m"
+    }
+    prompt_row, first_row := 1, 2
+    if tm.opts.Bottom {
+        prompt_row, first_row = tm.rows, tm.rows - h
+    }
+    
+    w.WriteString("\x1b[?25l\x1b[0m" + normal + "\x1b[2J")
+    for i := 0; i < h && tm.top + i < len(tm.matches); i++ {
+        fmt.Fprintf(w, "\x1b[%d;1H", first_row + i)
+        line := Truncate(tm.lines[tm.matches[tm.top + i]], tm.cols)
+        if tm.top + i == tm.cur {
+            w.WriteString(selected + "\x1b[2K" + Pad(line, tm.cols) +
+                          "\x1b[0m" + normal)
+        } else {
+            w.WriteString(line)
+        }
+    }
+    
+    // The end of the query is what's kept if it doesn't all fit.
+    input := tm.opts.Prompt
+    if !tm.opts.Password {
+        input += tm.query
+    }
+    for Width(input) >= tm.cols {
+        _, n := utf8.DecodeRuneInString(input)
+        input = input[n:]
+    }
+    fmt.Fprintf(w, "\x1b[%d;1H%s\x1b[?25h", prompt_row, input)
+}
+
+// termColor() returns the escape sequence that sets the foreground (or,
+// if bg is set, the background) to the given #rgb or #rrggbb color, or ""
+// if it isn't one of those.
+//
+func termColor(color string, bg bool) string {
+    rgb, ok := hexColor(color)
+    if !ok {
+        return ""
+    }
+    layer := 38
+    if bg {
+        layer = 48
+    }
+    return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, rgb >> 16, (rgb >> 8) & 0xff, rgb & 0xff)
+}
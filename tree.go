@@ -0,0 +1,176 @@
+// tree.go
+//
+// Menus of menus: picking something out of a tree of Items.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "context" )
+
+// A Branch is an Item that holds other Items, some of which may be
+// Branches themselves. Items that aren't Branches are the leaves.
+//
+type Branch interface {
+    Item
+    Children() ItemList
+}
+
+// A TreeMode says what can be chosen from a tree (see SelectTree()).
+//
+type TreeMode int
+
+const(
+    // TreeLeafOnly only lets leaves be chosen; choosing a Branch opens it.
+    TreeLeafOnly TreeMode = iota
+    // TreeBranchAllowed lets leaves or Branches be chosen. A Branch is
+    // chosen with the TreeChooseHere line at the top of its menu.
+    TreeBranchAllowed
+    // TreeBranchOnly only lets Branches be chosen, and leaves out the
+    // leaves (as when choosing where to put something new).
+    TreeBranchOnly
+)
+
+// TreeChooseHere is what the line that chooses the Branch being shown
+// says, after the separator (see TreeBranchAllowed).
+//
+var TreeChooseHere = "[ choose current category ]"
+
+// WithTreeMode() sets what can be chosen from a tree (the default is
+// TreeLeafOnly). It has no effect on other menus.
+//
+func WithTreeMode(mode TreeMode) Option { return func(o *Options) { o.treeMode = mode } }
+
+// WithSeparator() sets what goes between the keys of the Branches in a
+// tree menu's prompt (the default is "/"). It has no effect on other
+// menus.
+//
+func WithSeparator(sep string) Option { return func(o *Options) { o.treeSep = sep } }
+
+// treeHere is the Item for the TreeChooseHere line.
+//
+type treeHere struct {
+    line []byte
+}
+
+func (th *treeHere) Key() string { return "" }
+func (th *treeHere) MenuLine(int) []byte { return th.line }
+func (th *treeHere) SortsBefore(Item) bool { return true }
+
+// SelectTree() lets the user pick an Item out of the tree under root. See
+// the package-level SelectTree().
+//
+func (m *Menu) SelectTree(prompt string, root Branch, opts ...Option) (Item, error) {
+    return m.SelectTreeContext(context.Background(), prompt, root, opts...)
+}
+
+// SelectTreeContext() is like SelectTree(), but gives up when ctx
+// finishes.
+//
+func (m *Menu) SelectTreeContext(ctx context.Context, prompt string, root Branch, opts ...Option) (Item, error) {
+    path, err := m.SelectTreePathContext(ctx, prompt, root, opts...)
+    if len(path) == 0 {
+        return nil, err
+    }
+    return path[len(path)-1], err
+}
+
+// SelectTreePath() is like SelectTree(), but returns the whole path to the
+// Item chosen: root, the Branches under it that were opened to get there,
+// and then the Item itself (which is root again, if root was chosen).
+//
+func (m *Menu) SelectTreePath(prompt string, root Branch, opts ...Option) ([]Item, error) {
+    return m.SelectTreePathContext(context.Background(), prompt, root, opts...)
+}
+
+// SelectTreePathContext() is like SelectTreePath(), but gives up when ctx
+// finishes.
+//
+func (m *Menu) SelectTreePathContext(ctx context.Context, prompt string, root Branch, opts ...Option) ([]Item, error) {
+    o := m.options(prompt, 0, opts)
+    sep := orDefault(o.treeSep, "/")
+    here := &treeHere{ line: []byte(sep + " " + TreeChooseHere) }
+    
+    path := ItemList{ root }
+    var came_from Item
+    for {
+        list := make(ItemList, 0)
+        if o.treeMode != TreeLeafOnly {
+            list = append(list, here)
+        }
+        for _, itm := range path[len(path)-1].(Branch).Children() {
+            if _, is_branch := itm.(Branch); is_branch || o.treeMode != TreeBranchOnly {
+                list = append(list, itm)
+            }
+        }
+        crumbs := prompt
+        for _, b := range path[1:] {
+            crumbs += b.Key() + sep
+        }
+    
+        branch_opts := opts
+        if came_from != nil {
+            branch_opts = append(opts[:len(opts):len(opts)], WithDefault(came_from))
+        }
+        res, err := m.ChooseContext(ctx, crumbs, list, branch_opts...)
+        if err != nil {
+            return nil, err
+        }
+        switch x := res.Item.(type) {
+            case nil:
+                if !res.Cancelled {
+                    return nil, nil
+                }
+                // Escape goes back up a level, and dismisses the menu at
+                // the top.
+                if len(path) == 1 {
+                    return nil, ErrCancelled
+                }
+                came_from = path[len(path)-1]
+                path = path[:len(path)-1]
+            case *treeHere:
+                return path, nil
+            case Branch:
+                came_from = nil
+                path = append(path, x)
+            default:
+                return append(path, x), nil
+        }
+    }
+}
+
+// SelectTree() shows a menu of root's Children(). Choosing a Branch opens
+// another menu of its Children, whose prompt has the Branch's Key() and a
+// separator added on the end, and so on down; Escape goes back up a level
+// (and dismisses the menu at the top). What can be chosen depends on the
+// TreeMode (see WithTreeMode()); the default is only leaves. The separator
+// can be changed WithSeparator().
+//
+// Children are shown in the order they're given. As with DmenuSelect(),
+// the error is ErrCancelled if the user dismisses the menu, and if the
+// user types something that doesn't match, both return values are nil.
+//
+func SelectTree(prompt string, root Branch, opts ...Option) (Item, error) {
+    return Default.SelectTree(prompt, root, opts...)
+}
+
+// SelectTreeContext() is to SelectTree() as DmenuSelectContext() is to
+// DmenuSelect().
+//
+func SelectTreeContext(ctx context.Context, prompt string, root Branch, opts ...Option) (Item, error) {
+    return Default.SelectTreeContext(ctx, prompt, root, opts...)
+}
+
+// SelectTreePath() is to SelectTree() as Menu.SelectTreePath() is to
+// Menu.SelectTree().
+//
+func SelectTreePath(prompt string, root Branch, opts ...Option) ([]Item, error) {
+    return Default.SelectTreePath(prompt, root, opts...)
+}
+
+// SelectTreePathContext() is to SelectTreePath() as DmenuSelectContext()
+// is to DmenuSelect().
+//
+func SelectTreePathContext(ctx context.Context, prompt string, root Branch, opts ...Option) ([]Item, error) {
+    return Default.SelectTreePathContext(ctx, prompt, root, opts...)
+}
@@ -0,0 +1,114 @@
+// dmx-config.go
+//
+// a tool for checking dmx configuration files
+// see https://github.com/d2718/dmx/
+//
+// Usage:
+//
+// dmx-config check [file]
+//
+// checks the given configuration file (or the one the dmx utilities would
+// read, if none is given) and prints any problems it finds, one per line,
+// as file:line: message. It exits with status 1 if there were any.
+//
+// dmx-config backend [file]
+//
+// prints the kind of session this is (wayland, x11 or tty) and the backend
+// menus would be shown with here, given the BACKEND setting in that file.
+//
+package main
+
+import( "flag"; "fmt"; "os"
+        "github.com/d2718/dmx"
+)
+
+func die(err error, msgfmt string, args ...interface{}) {
+    fmt.Fprintf(os.Stderr, msgfmt, args...)
+    os.Exit(2)
+}
+
+func usage() {
+    fmt.Fprintf(os.Stderr, "usage: dmx-config check [file]\n")
+    fmt.Fprintf(os.Stderr, "       dmx-config backend [file]\n")
+    flag.PrintDefaults()
+}
+
+func init() {
+    // Keys used by the utilities that come with dmx, in their own
+    // [sections] or (the old way) at the top of the file.
+    tools := map[string][]string{
+        "dtodo":    { "path", "temp", "editor", "formatter", "viewer", "frecency",
+                      "confirm" },
+        "fdmcm":    { "clip_dir", "xclip_path", "wl_copy_path", "wl_paste_path",
+                      "confirm" },
+        "fdmfc":    { "show_hidden", "case_sensitive", "long", "frecency", "multiple" },
+        "fatdmenu": { "separator", "prompt", "frecency", "confirm" },
+    }
+    for tool, keys := range tools {
+        dmx.RegisterKeys(tool, keys...)
+        for _, k := range keys {
+            dmx.RegisterKeys("", k, tool + "_" + k)
+        }
+    }
+}
+
+func main() {
+    flag.Usage = usage
+    flag.Parse()
+    
+    if flag.NArg() < 1 || flag.NArg() > 2 {
+        usage()
+        os.Exit(2)
+    }
+    switch flag.Arg(0) {
+        case "check":
+            check(flag.Arg(1))
+        case "backend":
+            backend(flag.Arg(1))
+        default:
+            usage()
+            os.Exit(2)
+    }
+}
+
+// backend() prints which backend menus would be shown with.
+//
+func backend(fname string) {
+    var other_cfgs []string
+    if fname != "" {
+        other_cfgs = []string{ fname }
+    }
+    if err := dmx.Autoconfigure(other_cfgs); err != nil {
+        fmt.Fprintf(os.Stderr, "dmx-config: %v\n", err)
+    }
+    fmt.Printf("session: %s\n", dmx.DetectSession())
+    _, name, err := dmx.ChooseBackend()
+    if err != nil {
+        die(err, "dmx-config: %v\n", err)
+    }
+    fmt.Printf("backend: %s\n", name)
+}
+
+// check() prints the problems with the configuration file fname.
+//
+func check(fname string) {
+    if fname == "" {
+        fname = dmx.FindConfig(nil)
+        if fname == "" {
+            die(nil, "dmx-config: no configuration file found; looked in:\n    %v\n",
+                dmx.ConfigPaths(nil))
+        }
+    }
+    
+    probs, err := dmx.ValidateConfig(fname)
+    if err != nil {
+        die(err, "dmx-config: %v\n", err)
+    }
+    for _, p := range probs {
+        fmt.Println(p.Error())
+    }
+    if len(probs) > 0 {
+        os.Exit(1)
+    }
+    fmt.Printf("%s: ok\n", fname)
+}
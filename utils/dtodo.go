@@ -9,9 +9,9 @@
 package main
 
 import( "bufio"; "flag"; "fmt"; "io"; "os"; "os/exec"; "path/filepath";
-        "regexp"; "sort"; "strconv"; "strings"
-        "github.com/d2718/dconfig"
+        "regexp"; "sort"; "strconv"; "strings"; "time"
         "github.com/d2718/dmx"
+        "github.com/d2718/dmx/history"
 )
 
 const DEBUG = false
@@ -26,6 +26,8 @@ var(
     browserPath string = "/usr/bin/uzbl"
     listItemRe *regexp.Regexp
     fileNameRe *regexp.Regexp
+    dueRe *regexp.Regexp
+    hist *history.Store     // set if ranking by frecency (-r)
 )
 
 func rpt(msgfmt string, args ...interface{}) {
@@ -56,6 +58,22 @@ func (itm Item) MenuLine(w int) []byte {
     return []byte(fmt.Sprintf("%*d  %s\n", w, itm.N, itm.Title))
 }
 
+// Item is also a dmx.Urgenter: an item whose title has a due:YYYY-MM-DD
+// tag in it is urgent once that day is over, so backends that can (like
+// rofi) make overdue items stand out.
+//
+func (itm Item) Urgent() bool {
+    m := dueRe.FindStringSubmatch(itm.Title)
+    if m == nil {
+        return false
+    }
+    due, err := time.ParseInLocation("2006-01-02", m[1], time.Local)
+    if err != nil {
+        return false
+    }
+    return time.Now().After(due.AddDate(0, 0, 1))
+}
+
 func (itm Item) fileName() string { return fmt.Sprintf("%d.md", itm.N) }
 
 func (itm Item) path() string {
@@ -167,14 +185,72 @@ func createItem(itm *Item) error {
     return nil
 }
 
+// writeItem() creates the file for a new item, with the given notes
+// under its title, without bothering the editor.
+//
+func writeItem(itm *Item, notes string) error {
+    of, err := os.OpenFile(itm.path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+    if err != nil {
+        return err
+    }
+    fmt.Fprintf(of, "# %s\n\n", itm.Title)
+    if notes != "" {
+        fmt.Fprintf(of, "%s\n", notes)
+    }
+    return of.Close()
+}
+
+// askItem() asks, with a dmx.Form, for the title of a new item, and
+// optionally when it's due and some notes. It returns the title (with a
+// due: tag, if there's a due date) and the notes, and false if the user
+// gives up.
+//
+func askItem() (string, string, bool) {
+    ans, err := dmx.Form([]dmx.Field{
+        { Name: "title", Validate: dmx.NotEmpty },
+        { Name: "due", Prompt: "due (YYYY-MM-DD): ", Validate: validDate },
+        { Name: "notes" },
+    })
+    if err != nil {
+        if err != dmx.ErrCancelled {
+            rpt("dtodo: %v\n", err)
+        }
+        return "", "", false
+    }
+    title := ans["title"]
+    if due := strings.TrimSpace(ans["due"]); due != "" {
+        title += " due:" + due
+    }
+    return title, ans["notes"], true
+}
+
+// validDate() is the dmx.Field.Validate function for due dates, which can
+// be left blank.
+//
+func validDate(s string) error {
+    if s = strings.TrimSpace(s); s == "" {
+        return nil
+    }
+    if _, err := time.Parse("2006-01-02", s); err != nil {
+        return fmt.Errorf("not a YYYY-MM-DD date")
+    }
+    return nil
+}
+
+// itemID() is what an Item is recorded in the history under. (Its number
+// gets reused, but its title probably doesn't.)
+//
+func itemID(itm dmx.Item) string { return itm.(*Item).Title }
+
 func selectItem(il dmx.ItemList) *Item {
-    if altCfg == "" {
-        dmx.Autoconfigure(nil)
-    } else {
-        dmx.Autoconfigure([]string{altCfg})
+    if hist != nil {
+        il = append(make(dmx.ItemList, 0, len(il)), il...)
+        sort.Sort(hist.Sorter(il, itemID))
     }
     itm, err := dmx.DmenuSelect(">", il)
-    if err != nil {
+    if err == dmx.ErrCancelled {
+        return nil
+    } else if err != nil {
         rpt("Error in dmx.DmenuSelect: %v\n", err)
         return nil
     } else if itm == nil {
@@ -184,10 +260,17 @@ func selectItem(il dmx.ItemList) *Item {
     }
 }
 
+func saveHistory() {
+    if err := hist.Save(); err != nil {
+        rpt("dtodo: %v\n", err)
+    }
+}
+
 func init() {
     itemsPath = os.ExpandEnv("$HOME/.dtodo")
     editorPath = os.Getenv("EDITOR")
     listItemRe = regexp.MustCompile(`^(\d+)\s+(.+)$`)
+    dueRe = regexp.MustCompile(`\bdue:(\d{4}-\d{2}-\d{2})\b`)
     listPath = filepath.Join(itemsPath, "list.txt")
 }
 
@@ -196,30 +279,55 @@ func main() {
     var addDesc     string = ""
     var doExpunge     bool = false
     var doTidy        bool = false
+    var byFrecency    bool = false
+    var doConfirm     bool = false
+    var askAdd        bool = false
     
     flag.BoolVar(&viewFormatted, "p", false, "view Prettily-formatted output")
     flag.StringVar(&addDesc,     "a", "",    "Add new item")
+    flag.BoolVar(&askAdd,        "A", false, "Add new item, Asking for it with menus (also -a '')")
     flag.BoolVar(&doExpunge,     "x", false, "eXpunge a single item (it's done!)")
     flag.BoolVar(&doTidy,        "t", false, "Tidy the list directory")
+    flag.BoolVar(&byFrecency,    "r", false, "Rank items by how often and how Recently they're chosen")
+    flag.BoolVar(&doConfirm, "confirm", false, "ask for CONFIRMation before expunging")
     flag.StringVar(&altCfg, "config", "",    "specify alternate CONFIGuration file")
+    flag.StringVar(&dmx.Default.Theme, "theme", "", "use the named THEME from the configuration file")
     flag.Parse()
     if addDesc != "" {
         addDesc = strings.Join(append([]string{addDesc}, flag.Args()...), " ")
     }
-    config_files := make([]string, 0, 3)
-    if altCfg != "" {
-        config_files = append(config_files, altCfg)
-    }
-    config_files = append(config_files, os.ExpandEnv("$HOME/.config/dmx.conf"))
-    config_files = append(config_files, "/usr/share/dmx.conf")
+    // -a '' asks for the new item with menus, too.
+    flag.Visit(func(f *flag.Flag) {
+        if f.Name == "a" && addDesc == "" {
+            askAdd = true
+        }
+    })
     
-    dconfig.Reset()
-    dconfig.AddString(&itemsPath,     "dtodo_path",      dconfig.STRIP)
-    dconfig.AddString(&tempDir,       "dtodo_temp",      dconfig.STRIP)
-    dconfig.AddString(&editorPath,    "editor",          dconfig.STRIP)
-    dconfig.AddString(&formatterPath, "dtodo_formatter", dconfig.STRIP)
-    dconfig.AddString(&browserPath,   "dtodo_viewer",    dconfig.STRIP)
-    dconfig.Configure(config_files, false)
+    cfg := dmx.NewLoader("dtodo")
+    cfg.String(&itemsPath,     "path",      "")
+    cfg.String(&tempDir,       "temp",      "")
+    cfg.String(&editorPath,    "editor",    "")
+    cfg.String(&formatterPath, "formatter", "")
+    cfg.String(&browserPath,   "viewer",    "")
+    cfg.Bool(&byFrecency,      "frecency",  "r")
+    cfg.Bool(&doConfirm,       "confirm",   "confirm")
+    var cfg_err error
+    if altCfg == "" {
+        cfg_err = cfg.Load(nil)
+    } else {
+        cfg_err = cfg.Load([]string{altCfg})
+    }
+    if cfg_err != nil {
+        fmt.Fprintf(os.Stderr, "dtodo: %v\n", cfg_err)
+    }
+    listPath = filepath.Join(itemsPath, "list.txt")
+    if byFrecency {
+        var err error
+        hist, err = history.Open("dtodo")
+        if err != nil {
+            rpt("dtodo: %v\n", err)
+        }
+    }
     
     lst, err := readList()
     if err != nil {
@@ -227,7 +335,14 @@ func main() {
     }
     sort.Sort(lst)
     
-    if addDesc != "" {
+    if addDesc != "" || askAdd {
+        var notes string
+        if askAdd {
+            var ok bool
+            if addDesc, notes, ok = askItem(); !ok {
+                os.Exit(0)
+            }
+        }
         n_sup := len(lst) + 1
         for n, itm := range lst {
             x := itm.(*Item)
@@ -238,7 +353,11 @@ func main() {
         }
         
         nitm_p := &Item{ N: n_sup, Title: addDesc, }
-        err = createItem(nitm_p)
+        if askAdd {
+            err = writeItem(nitm_p, notes)
+        } else {
+            err = createItem(nitm_p)
+        }
         if err != nil {
             die(err, "Unable to create item %v: %s\n", err)
         }
@@ -253,6 +372,15 @@ func main() {
         if it == nil {
             os.Exit(0)
         }
+        if doConfirm {
+            yes, err := dmx.Confirm(fmt.Sprintf("expunge %d (%s)?", it.N, it.Title))
+            if err != nil {
+                rpt("dtodo: %v\n", err)
+            }
+            if !yes {
+                os.Exit(0)
+            }
+        }
         
         idx := -1
         for n, itm := range lst {
@@ -263,6 +391,10 @@ func main() {
         }
         if idx > -1 {
             lst = append(lst[:idx], lst[idx+1:]...)
+            if hist != nil {
+                hist.Forget(itemID(it))
+                saveHistory()
+            }
             if !doTidy {
                 err := writeList(lst)
                 if err != nil {
@@ -274,6 +406,10 @@ func main() {
     } else {
         it := selectItem(lst)
         if it != nil {
+            if hist != nil {
+                hist.Record(itemID(it))
+                saveHistory()
+            }
             if viewFormatted {
                 err = it.prettyPrint()
                 if err != nil {
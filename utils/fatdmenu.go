@@ -8,8 +8,9 @@
 //
 package main
 
-import( "encoding/json"; "flag"; "fmt"; "os"; "sort"
+import( "encoding/json"; "flag"; "fmt"; "os"; "sort"; "strings"
         "github.com/d2718/dmx"
+        "github.com/d2718/dmx/history"
 )
 
 const DEBUG bool = false
@@ -18,7 +19,7 @@ var(
     separator string
     basePrompt string
     dataFileMode os.FileMode = 0664
-    catSelector *SpecialEntry
+    hist *history.Store     // nil unless ranking by frecency (-r)
 )
 
 func dbglog(fmtstr string, args ...interface{}) {
@@ -48,20 +49,30 @@ type Category struct {
     Stuff dmx.ItemList `json:"stuff"`
 }
 
-// Entry and Category both implement dmx.Item
+// Entry and Category both implement dmx.Item, and Category implements
+// dmx.Branch.
+//
+// Categories are shown with the separator after their keys, so every
+// line's key is padded out to leave room for one.
 //
 func (ent Entry) Key() string {
     return ent.Token
 }
 func (cat Category) Key() string {
-    return fmt.Sprintf("%s%s", cat.Token, separator)
+    return cat.Token
 }
 
 func (ent Entry) MenuLine(width int) []byte {
-    return []byte(fmt.Sprintf("%-*s    %s\n", width, ent.Token, ent.Desc))
+    width += dmx.Width(separator)
+    return []byte(fmt.Sprintf("%s    %s\n", dmx.Pad(ent.Token, width), ent.Desc))
 }
 func (cat Category) MenuLine(width int) []byte {
-    return []byte(fmt.Sprintf("%-*s    %s\n", width, cat.Key(), cat.Desc))
+    width += dmx.Width(separator)
+    return []byte(fmt.Sprintf("%s    %s\n", dmx.Pad(cat.Token + separator, width), cat.Desc))
+}
+
+func (cat Category) Children() dmx.ItemList {
+    return cat.Stuff
 }
 
 func (ent Entry) SortsBefore(itm dmx.Item) bool {
@@ -118,16 +129,6 @@ func (cat *Category) Expunge(itm dmx.Item) bool {
     return false
 }
 
-// SpecialEntry represents the "choose current directory" option.
-// Obviously, it implements dmx.Item.
-//
-type SpecialEntry struct {
-    line []byte
-}
-func (se SpecialEntry) Key() string { return "" }
-func (se SpecialEntry) SortsBefore(itm dmx.Item) bool { return true }
-func (se SpecialEntry) MenuLine(_ int) []byte { return se.line }
-
 // InterpretItem() turns an interface{} Unmarshall()ed from a JSON entry
 // into the appropriate type of item (recursively, with Categories).
 //
@@ -232,67 +233,103 @@ func writeFile(data_file string, base_cat *Category) {
     }
 }
 
-// heiroSelect() is the meat. It recursively calls dmenu to select an Item
-// from the supplied *Category's heirarchy of Items.
-//
-// It allows for the option to select categories (instead of just entries),
-// and to select ONLY categories (as when choosing where to insert a
-// new Item).
+// itemID() is the ID itm is recorded under in the history: its path from
+// the top category, like "work/mail" (or "work/" for the category itself).
+// path is the path to the category it's in, like "work/".
 //
-func heiroSelect(cat *Category, prompt string,
-                 canSelectCat, onlySelectCat bool) dmx.Item {
+func itemID(path string, itm dmx.Item) string {
+    if cat, is_cat := itm.(*Category); is_cat {
+        return path + cat.Token + separator
+    }
+    return path + itm.Key()
+}
 
+// sortTree() sorts the contents of cat and all the categories under it:
+// categories first, then entries, alphabetically, unless ranking by
+// frecency is wanted, in which case the most frecent come first. path is
+// the path to cat (see itemID()).
+//
+func sortTree(cat *Category, path string, by_frecency bool) {
     sort.Sort(cat.Stuff)
-    list_len := len(cat.Stuff)
-    if canSelectCat {
-        list_len += 1
-    }
-    new_list := make(dmx.ItemList, 0, list_len)
-    if canSelectCat {
-        new_list = append(new_list, catSelector)
+    if by_frecency && hist != nil {
+        sort.Sort(hist.Sorter(cat.Stuff, func(itm dmx.Item) string {
+            return itemID(path, itm)
+        }))
     }
     for _, itm := range cat.Stuff {
-        switch x := itm.(type) {
-            case *Category:
-                new_list = append(new_list, x)
-            case *Entry:
-                if !onlySelectCat {
-                    new_list = append(new_list, x)
-                }
-            default:    // shouldn't happen
-                continue
+        if x, is_cat := itm.(*Category); is_cat {
+            sortTree(x, itemID(path, x), by_frecency)
         }
     }
-    
-    for {
-        choice, err := dmx.DmenuSelect(prompt, new_list)
-        if err != nil {
-            dbglog("Error in dmx.DmenuSelect(): %v\n", err)
-            return nil
+}
+
+// heiroSelect() is the meat. It shows the supplied *Category's heirarchy
+// of Items as a tree of menus (see dmx.SelectTree()), and returns the Item
+// chosen, or nil if there wasn't one. mode says whether entries,
+// categories, or either can be chosen.
+//
+// The chosen Item, and each category opened to get to it, are recorded in
+// the history, if there is one.
+//
+func heiroSelect(cat *Category, mode dmx.TreeMode) dmx.Item {
+    path, err := dmx.SelectTreePath(basePrompt, cat,
+                                    dmx.WithTreeMode(mode), dmx.WithSeparator(separator))
+    if err != nil || len(path) == 0 {
+        dbglog("Error in dmx.SelectTreePath(): %v\n", err)
+        return nil
+    }
+    if hist != nil {
+        id_path := ""
+        for _, itm := range path[1:] {
+            hist.Record(itemID(id_path, itm))
+            id_path = itemID(id_path, itm)
         }
-        
-        switch x := choice.(type) {
-            case *Entry:
-                return x
-            case *SpecialEntry:
-                if x == catSelector {
-                    return cat
-                } else {
-                    dbglog("DmenuSelect() returns unexpected *SpecialEntry: %v\n", x)
-                    return nil
-                }
-            case *Category:
-                new_prompt := prompt + x.Key()
-                new_rval := heiroSelect(x, new_prompt, canSelectCat,
-                                        onlySelectCat)
-                if new_rval != nil {
-                    return new_rval
-                }
-            default:   // shouldn't happen
-                dbglog("In heiroSelect(): dmx.DmenuSelect() returns item which falls through switch statement: %v\n", x)
-                return nil
+    }
+    return path[len(path)-1]
+}
+
+// askNew() asks, with a dmx.Form, for whichever of the new item's key,
+// description and value (which categories don't have) weren't given on
+// the command line. It returns false if the user gives up.
+//
+func askNew(key, desc, val *string, is_cat bool) bool {
+    fields := make([]dmx.Field, 0, 3)
+    if *key == "" {
+        fields = append(fields, dmx.Field{ Name: "key", Validate: validKey })
+    }
+    if *desc == "" {
+        fields = append(fields, dmx.Field{ Name: "description", Validate: dmx.NotEmpty })
+    }
+    if *val == "" && !is_cat {
+        fields = append(fields, dmx.Field{ Name: "value", Validate: dmx.NotEmpty })
+    }
+    if len(fields) == 0 {
+        return true
+    }
+    
+    ans, err := dmx.Form(fields)
+    if err != nil {
+        dbglog("Error in dmx.Form(): %v\n", err)
+        return false
+    }
+    for name, p := range map[string]*string{ "key": key, "description": desc, "value": val } {
+        if v, ok := ans[name]; ok {
+            *p = v
         }
     }
+    return true
+}
+
+// validKey() is the dmx.Field.Validate function for new keys, which can't
+// be empty, or have the separator in them.
+//
+func validKey(key string) error {
+    if err := dmx.NotEmpty(key); err != nil {
+        return err
+    } else if separator != "" && strings.Contains(key, separator) {
+        return fmt.Errorf("can't contain %q", separator)
+    }
+    return nil
 }
 
 func main() {
@@ -306,6 +343,8 @@ func main() {
     var newDesc string = ""
     var newVal string = ""
     var altCfg string = ""
+    var byFrecency bool = false
+    var doConfirm bool = false
     
     flag.StringVar(&separator,    "s", "/",    "category Separator")
     flag.StringVar(&basePrompt,   "p", "",     "base Prompt")
@@ -315,19 +354,28 @@ func main() {
     flag.StringVar(&outputFormat, "f", "%s\n", "output Format string (include a %s!)")
     flag.StringVar(&outputFile,   "o", "",     "Output file")
     flag.BoolVar(&appendOutput,   "a", false,  "Append to output file instead of overwriting")
-    flag.StringVar(&newKey,       "k", "",     "new Key for added item")
-    flag.StringVar(&newDesc,      "d", "",     "new Description for added item")
-    flag.StringVar(&newVal,       "v", "",     "new output Value for added item")
+    flag.StringVar(&newKey,       "k", "",     "new Key for added item (asked for if not given)")
+    flag.StringVar(&newDesc,      "d", "",     "new Description for added item (asked for if not given)")
+    flag.StringVar(&newVal,       "v", "",     "new output Value for added item (asked for if not given)")
+    flag.BoolVar(&byFrecency,     "r", false,  "Rank items by how often and how Recently they're chosen")
+    flag.BoolVar(&doConfirm, "confirm", false, "ask for CONFIRMation before expunging")
     flag.StringVar(&altCfg,  "config", "",     "specify an alternate CONFIGuration file")
+    flag.StringVar(&dmx.Default.Theme, "theme", "", "use the named THEME from the configuration file")
     flag.Parse()
-    catSelector = &SpecialEntry{
-                    line: []byte(fmt.Sprintf("%s [ choose current category ]\n",
-                                     separator)),
-                }
+    
+    cfg := dmx.NewLoader("fatdmenu")
+    cfg.String(&separator,  "separator", "s")
+    cfg.String(&basePrompt, "prompt",    "p")
+    cfg.Bool(&byFrecency,   "frecency",  "r")
+    cfg.Bool(&doConfirm,    "confirm",   "confirm")
+    var cfg_err error
     if altCfg == "" {
-        dmx.Autoconfigure(nil)
+        cfg_err = cfg.Load(nil)
     } else {
-        dmx.Autoconfigure([]string{altCfg})
+        cfg_err = cfg.Load([]string{altCfg})
+    }
+    if cfg_err != nil {
+        fmt.Fprintf(os.Stderr, "fatdmenu: %v\n", cfg_err)
     }
     data_file := flag.Arg(0)
     if data_file == "" {
@@ -338,22 +386,24 @@ func main() {
     if err != nil {
         die(err, "Error reading data file %#v.\n", data_file)
     }
+    if byFrecency {
+        hist, err = history.Open("fatdmenu")
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "fatdmenu: %v\n", err)
+        }
+    }
+    // Entries and categories are only rearranged by frecency for choosing
+    // an entry, so that adding or expunging doesn't write them back out in
+    // that order.
+    sortTree(base_cat_p, "", !addItem && !expungeItem)
 
     if addItem {
-        if newKey == "" {
-            die(nil, "You must specify a key with the -k flag.\n")
-        } else if newDesc == "" {
-            die(nil, "You must specify a description with the -d flag.\n")
-        }
-        if !selectCat {
-            if newVal == "" {
-                die(nil, "You must specify a value with the -v flag.\n")
-            }
+        if !askNew(&newKey, &newDesc, &newVal, selectCat) {
+            return
         }
         
-        containerCat := heiroSelect(base_cat_p, basePrompt,
-                                    true, true).(*Category)
-        if containerCat == nil {
+        containerCat, ok := heiroSelect(base_cat_p, dmx.TreeBranchOnly).(*Category)
+        if !ok {
             return
         }
         if selectCat {
@@ -375,15 +425,29 @@ func main() {
         writeFile(data_file, base_cat_p)
         
     } else if expungeItem {
-        old_itm := heiroSelect(base_cat_p, basePrompt, true, false)
+        old_itm := heiroSelect(base_cat_p, dmx.TreeBranchAllowed)
+        if old_itm != nil && doConfirm {
+            yes, err := dmx.Confirm(fmt.Sprintf("expunge %s?", old_itm.Key()))
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "fatdmenu: %v\n", err)
+            }
+            if !yes {
+                return
+            }
+        }
         if old_itm != nil {
             base_cat_p.Expunge(old_itm)
             writeFile(data_file, base_cat_p)
         }
         
     } else {
-        uncast_item := heiroSelect(base_cat_p, basePrompt, false, false)
+        uncast_item := heiroSelect(base_cat_p, dmx.TreeLeafOnly)
         if uncast_item != nil {
+            if hist != nil {
+                if err := hist.Save(); err != nil {
+                    fmt.Fprintf(os.Stderr, "fatdmenu: %v\n", err)
+                }
+            }
             the_item := uncast_item.(*Entry)
             if outputFile != "" {
                 var of_mode os.FileMode = 0664
@@ -16,21 +16,32 @@
 // to bind Windows-C and Windows-V to "cutting" from the primary X selection
 // and "pasting" to the clipboard respectively.
 //
-// Requires xclip ( https://github.com/astrand/xclip )
+// Requires xclip ( https://github.com/astrand/xclip ), or on Wayland,
+// wl-clipboard ( https://github.com/bugaevc/wl-clipboard ).
 //
 package main
 
-import( "bytes"; "flag"; "fmt"; "io"; "os"; "os/exec"; "path/filepath"
-        "regexp"; "sort"; "strconv"
-        "github.com/d2718/dconfig"
+import( "bytes"; "context"; "flag"; "fmt"; "io"; "os"; "os/exec"
+        "path/filepath"; "regexp"; "sort"; "strconv"; "time"
         "github.com/d2718/dmx" )
 
 const DEBUG bool = false
 
+// dangerTheme is the theme used for menus that delete things, if the
+// configuration file has one, unless a theme is chosen with --theme or
+// $DMX_THEME. (A THEME in the file is only the usual look, so it doesn't
+// count.)
+//
+const dangerTheme = "danger"
+
 var(
     xclipPath string = "/usr/bin/xclip"
+    wlCopyPath string = "wl-copy"
+    wlPastePath string = "wl-paste"
     clipDir string = "/tmp/fdmcm"
     maxPrevLength int = 512
+    menuTimeout time.Duration = 0
+    themeFlag string = ""
     numericRe *regexp.Regexp
 )
 
@@ -161,17 +172,122 @@ func getClips() dmx.ItemList {
 
 // selectClip() runs dmenu externally to select a clipboard file.
 //
+// If a menu timeout has been set and dmenu is still waiting for the user
+// when it expires, dmenu is killed and this exits with an error; the user
+// dismissing the menu just returns nil.
+//
 func selectClip(prompt string) *Entry {
     clips := getClips()
     
-    clip, err := dmx.DmenuSelect(prompt, clips)
-    if err != nil {
+    ctx := context.Background()
+    if menuTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, menuTimeout)
+        defer cancel()
+    }
+    
+    opts := make([]dmx.Option, 0, 1)
+    if len(clips) > 0 {
+        opts = append(opts, dmx.WithDefault(clips[0]))     // the newest
+    }
+    clip, err := dmx.DmenuSelectContext(ctx, prompt, clips, opts...)
+    if err == context.DeadlineExceeded {
+        die(err, "fdmcm: menu timed out after %v.\n", menuTimeout)
+    } else if err != nil || clip == nil {
         return nil
-    } else {
-        return clip.(*Entry)
+    }
+    return clip.(*Entry)
+}
+
+// selectClips() is like selectClip(), but lets the user choose several
+// clipboard files (with dmenu's Ctrl-Enter, or the like), and returns them
+// in the order they were chosen.
+//
+func selectClips(prompt string) []*Entry {
+    clips := getClips()
+    
+    ctx := context.Background()
+    if menuTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, menuTimeout)
+        defer cancel()
+    }
+    
+    opts := make([]dmx.Option, 0, 1)
+    if len(clips) > 0 {
+        opts = append(opts, dmx.WithDefault(clips[0]))
+    }
+    chosen, err := dmx.DmenuSelectMultiContext(ctx, prompt, clips, opts...)
+    if err == context.DeadlineExceeded {
+        die(err, "fdmcm: menu timed out after %v.\n", menuTimeout)
+    } else if err != nil {
+        return nil
+    }
+    entries := make([]*Entry, 0, len(chosen))
+    for _, clip := range chosen {
+        entries = append(entries, clip.(*Entry))
+    }
+    return entries
+}
+
+// useWayland() reports whether to use wl-clipboard rather than xclip: in a
+// Wayland session, if it's installed. (xclip only sees the clipboards of
+// programs running under XWayland.)
+//
+func useWayland() bool {
+    if dmx.DetectSession() != dmx.SessionWayland {
+        return false
+    }
+    _, err := exec.LookPath(wlPastePath)
+    return err == nil
+}
+
+// primaryCmd() returns a command that prints the PRIMARY selection.
+//
+func primaryCmd() *exec.Cmd {
+    if useWayland() {
+        return exec.Command(wlPastePath, "--primary", "--no-newline")
+    }
+    return exec.Command(xclipPath, "-selection", "primary", "-o")
+}
+
+// clipboardCmd() returns a command that puts what it reads into the
+// CLIPBOARD selection.
+//
+func clipboardCmd() *exec.Cmd {
+    if useWayland() {
+        return exec.Command(wlCopyPath)
+    }
+    return exec.Command(xclipPath, "-selection", "clipboard", "-i")
+}
+
+// useDangerTheme() makes the menus that follow look different, since
+// they delete things: it applies dangerTheme, if there is one, unless the
+// user asked for a specific theme.
+//
+func useDangerTheme() {
+    if themeFlag == "" && os.Getenv("DMX_THEME") == "" && dmx.Default.HasTheme(dangerTheme) {
+        dmx.ApplyTheme(dangerTheme)
     }
 }
 
+// confirm() asks the user a yes-or-no question, and reports whether the
+// answer was yes. A timeout counts as no.
+//
+func confirm(question string) bool {
+    ctx := context.Background()
+    if menuTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, menuTimeout)
+        defer cancel()
+    }
+    yes, err := dmx.ConfirmContext(ctx, question)
+    if err != nil && err != context.DeadlineExceeded {
+        fmt.Fprintf(os.Stderr, "fdmcm: %v\n", err)
+    }
+    return yes
+}
+
 func init() {
     var err error
     
@@ -179,11 +295,6 @@ func init() {
     if err != nil {
         die(err, "Unable to compile regexp.\n")
     }
-    
-    err = os.MkdirAll(clipDir, 0775)
-    if err != nil {
-        die(err, "Unable to ensure existence of clip directory %#v.\n", clipDir)
-    }
 }
 
 func main() {
@@ -191,37 +302,47 @@ func main() {
     var doRecall  bool = false
     var doExpunge bool = false
     var doPurge   bool = false
+    var doConfirm bool = false
     var altCfg  string = ""
     
     flag.BoolVar(&doSave,    "s", false, "Save current PRIMARY selection")
     flag.BoolVar(&doRecall,  "r", false, "Recall saved selection to CLIPBOARD")
     flag.BoolVar(&doExpunge, "x", false, "eXpunge a specific clipboard item")
     flag.BoolVar(&doPurge,   "p", false, "Purge _all_ clipboard items")
+    flag.BoolVar(&doConfirm, "confirm", false, "ask for CONFIRMation before deleting anything")
     flag.StringVar(&clipDir, "d", "/tmp/fdmcm", "specify an alternate Directory for clipboard files")
     flag.StringVar(&altCfg,  "config", "", "specify an alternate CONFIGuration file")
+    flag.StringVar(&themeFlag, "theme", "", "use the named THEME from the configuration file")
+    flag.DurationVar(&menuTimeout, "t", 0, "Timeout for menus, e.g. 30s (0 waits forever)")
     flag.Parse()
-    clipDir, err := filepath.Abs(clipDir)
-    if err != nil {
-        die(err, "Error with specified clipboard directory %#v.\n", clipDir)
-    }
-    dbglog("clipboard directory: %v\n", clipDir)
+    
+    dmx.Default.Theme = themeFlag
+    cfg := dmx.NewLoader("fdmcm")
+    cfg.String(&clipDir, "clip_dir", "d")
+    cfg.String(&xclipPath, "xclip_path", "")
+    cfg.String(&wlCopyPath, "wl_copy_path", "")
+    cfg.String(&wlPastePath, "wl_paste_path", "")
+    cfg.Bool(&doConfirm, "confirm", "confirm")
+    var cfg_err error
     if altCfg == "" {
-        dmx.Autoconfigure(nil)
+        cfg_err = cfg.Load(nil)
     } else {
-        dmx.Autoconfigure([]string{altCfg})
+        cfg_err = cfg.Load([]string{altCfg})
     }
-    
-    cfg_files := make([]string, 0, 3)
-    if altCfg != "" {
-        cfg_files = append(cfg_files, altCfg)
+    if cfg_err != nil {
+        fmt.Fprintf(os.Stderr, "fdmcm: %v\n", cfg_err)
     }
-    cfg_files = append(cfg_files, os.ExpandEnv("$HOME/.config/dmx.conf"))
-    cfg_files = append(cfg_files, "/usr/share/dmx.conf")
     
-    dconfig.Reset()
-    dconfig.AddString(&xclipPath, "xclip_path", dconfig.STRIP)
-    dconfig.AddString(&clipDir, "fdmcm_clip_dir", dconfig.STRIP)
-    dconfig.Configure(cfg_files, false)
+    var err error
+    clipDir, err = filepath.Abs(clipDir)
+    if err != nil {
+        die(err, "Error with specified clipboard directory %#v.\n", clipDir)
+    }
+    dbglog("clipboard directory: %v\n", clipDir)
+    err = os.MkdirAll(clipDir, 0775)
+    if err != nil {
+        die(err, "Unable to ensure existence of clip directory %#v.\n", clipDir)
+    }
     
     if doSave {
         var next_n int = 0
@@ -240,11 +361,11 @@ func main() {
             fmt.Fprintf(os.Stderr, "Unable to change mode of clipboard file %#v.\n", new_path)
         }
         
-        xcmd := exec.Command(xclipPath, "-selection", "primary", "-o")
+        xcmd := primaryCmd()
         xcmd.Stdout = f_out
         err = xcmd.Run()
         if err != nil {
-            die(err, "Error executing external process %#v.\n", xclipPath)
+            die(err, "Error executing external process %#v.\n", xcmd.Path)
         }
     
     } else if doRecall {
@@ -253,7 +374,7 @@ func main() {
             os.Exit(0)
         }
         
-        xcmd := exec.Command(xclipPath, "-selection", "clipboard", "-i")
+        xcmd := clipboardCmd()
         f_in, err := os.Open(c.path)
         if err != nil {
             die(err, "Unable to open clipboard file %#v.\n", c.path)
@@ -261,23 +382,41 @@ func main() {
         xcmd.Stdin = f_in
         err = xcmd.Run()
         if err != nil {
-            die(err, "Error running external process %#v.\n", xclipPath)
+            die(err, "Error running external process %#v.\n", xcmd.Path)
         }
         
     } else if doExpunge {
-        c := selectClip("X>")
-        if c == nil {
+        useDangerTheme()
+        cs := selectClips("X>")
+        if len(cs) == 0 {
             os.Exit(0)
         }
+        if doConfirm {
+            question := fmt.Sprintf("expunge clip %d?", cs[0].n)
+            if len(cs) > 1 {
+                question = fmt.Sprintf("expunge %d clips?", len(cs))
+            }
+            if !confirm(question) {
+                os.Exit(0)
+            }
+        }
         
-        err := os.Remove(c.path)
-        if err != nil {
-            die(err, "Unable to remove clipboard file %#v.\n", c.path)
+        for _, c := range cs {
+            err := os.Remove(c.path)
+            if err != nil {
+                die(err, "Unable to remove clipboard file %#v.\n", c.path)
+            }
         }
         
     } else if doPurge {
         var ep *Entry
         clips := getClips()
+        if doConfirm {
+            useDangerTheme()
+            if !confirm(fmt.Sprintf("purge all %d clips?", len(clips))) {
+                os.Exit(0)
+            }
+        }
         for _, c := range clips {
             ep = c.(*Entry)
             err := os.Remove(ep.path)
@@ -8,17 +8,24 @@
 //
 package main
 
-import( "flag"; "fmt"; "os"; "path/filepath"; "sort"; "strings"
+import( "flag"; "fmt"; "mime"; "os"; "path/filepath"; "sort"; "strings"
         "github.com/d2718/dmx"
+        "github.com/d2718/dmx/history"
 )
 
 const DEBUG bool = false
 
 var caseSensitiveSort bool = false          // set by cmd-line flag
+var longListing bool = false                // set by cmd-line flag
+var multiple bool = false                   // set by cmd-line flag
+var longTable = &dmx.Table{                 // name, size, mtime
+    Columns: []dmx.Column{ { Max: 48 }, { Align: dmx.AlignRight }, {} },
+}
 var pathSeparator rune = '/'                // set by OS in init()
 var hiddenIndicator byte = byte('.')        // *nix-specific?
 var directorySelector *DirEntry             // created in init()
 var hiddenShower, hiddenHider *DirEntry     // created in init()
+var hist *history.Store                     // set if ranking by frecency (-r)
 
 func dbglog(fmtstr string, args ...interface{}) {
     if DEBUG {
@@ -43,6 +50,7 @@ type DirEntry struct {
     name  string
     isDir bool
     sort  string
+    info  os.FileInfo       // nil for the special entries
 }
 
 // NewDirEntry() creates a new DirEntry struct from an instance of
@@ -52,6 +60,7 @@ func NewDirEntry(fi os.FileInfo) *DirEntry {
     de := DirEntry{
         name: fi.Name(),
         isDir: fi.IsDir(),
+        info: fi,
     }
     if caseSensitiveSort {
         de.sort = de.name
@@ -73,6 +82,60 @@ func (de DirEntry) MenuLine(_ int) []byte {
     }
 }
 
+// DirEntry also implements dmx.Celler, for the long listing.
+//
+func (de DirEntry) Cells() []string {
+    if de.info == nil {
+        return []string{ de.name }
+    }
+    var size string
+    if de.isDir {
+        size = "-"
+    } else {
+        size = humanSize(de.info.Size())
+    }
+    return []string{ string(de.MenuLine(0)), size,
+                     de.info.ModTime().Format("2006-01-02 15:04") }
+}
+
+// DirEntry is also a dmx.Iconer, so that backends that can show icons
+// (like rofi) show what kind of file each one is.
+//
+func (de DirEntry) Icon() string {
+    switch {
+        case de.isDir:
+            return "folder"
+        case de.info == nil:
+            return ""
+        case de.info.Mode() & os.ModeSymlink != 0:
+            return "inode-symlink"
+        case de.info.Mode() & 0111 != 0:
+            return "application-x-executable"
+    }
+    typ, _, _ := strings.Cut(mime.TypeByExtension(filepath.Ext(de.name)), "/")
+    switch typ {
+        case "image", "audio", "video", "text":
+            return typ + "-x-generic"
+    }
+    return "text-x-generic"
+}
+
+// humanSize() formats a file size in bytes the way ls -h does.
+//
+func humanSize(n int64) string {
+    if n < 1024 {
+        return fmt.Sprintf("%d", n)
+    }
+    f := float64(n)
+    for _, unit := range []string{ "K", "M", "G", "T" } {
+        f = f / 1024
+        if f < 1024 {
+            return fmt.Sprintf("%.1f%s", f, unit)
+        }
+    }
+    return fmt.Sprintf("%.1fP", f / 1024)
+}
+
 func (de DirEntry) SortsBefore(itm dmx.Item) bool {
     rhs := itm.(*DirEntry)
     if de.isDir == rhs.isDir {
@@ -94,6 +157,15 @@ func isHidden(fi os.FileInfo) bool {
     return fi.Name()[0] == hiddenIndicator      // similarly hacky
 }
 
+// remember() records in the history (if there is one) that path was
+// chosen.
+//
+func remember(path string) {
+    if hist != nil {
+        hist.Record(path)
+    }
+}
+
 // parsePath() splits a path into a heirarchical series of names.
 //
 // Example:
@@ -118,11 +190,21 @@ func parsePath(pathname string) []string {
 // selectPath() is the meat. It repeatedly runs dmenu so the user can
 // navigate through the filesystem and select a path.
 //
+// Dismissing the menu goes up a directory. Typing a path that matches no
+// entry (relative to the current directory, or absolute) either moves to
+// that directory if it is one, or returns it as the selection, so the user
+// can name files that don't exist yet.
+//
+// With -m, several entries can be chosen from one directory at once
+// (with dmenu's Ctrl-Enter, or the like); then all the files among them
+// (and, with -d, the directories) are returned, and the rest are ignored.
+//
 // For proper function, the string slice argument should be the output of
 // parsePath() called on an actual directory.
 //
-func selectPath(pathElts []string, returnDir bool, showHidden bool) string {
+func selectPath(pathElts []string, returnDir bool, showHidden bool) []string {
     var cur_path string
+    var came_from string    // the directory we just went up out of
     for {
         cur_path = filepath.Join(pathElts...)
         df, err := os.Open(cur_path)
@@ -154,35 +236,124 @@ func selectPath(pathElts []string, returnDir bool, showHidden bool) string {
         } else {
             sort.Sort(entriez[1:])
         }
+        if hist != nil {
+            dir := cur_path
+            item_id := func(itm dmx.Item) string {
+                return filepath.Join(dir, itm.(*DirEntry).name)
+            }
+            if returnDir {
+                sort.Sort(hist.Sorter(entriez[2:], item_id))
+            } else {
+                sort.Sort(hist.Sorter(entriez[1:], item_id))
+            }
+        }
         
-        dmx_output, err := dmx.DmenuSelect(cur_path, entriez)
+        opts := make([]dmx.Option, 0, 3)
+        if !caseSensitiveSort {
+            opts = append(opts, dmx.WithCaseInsensitive(true))
+        }
+        if longListing {
+            opts = append(opts, dmx.WithTable(longTable))
+        }
+        if came_from != "" {
+            for _, itm := range entriez {
+                if de := itm.(*DirEntry); de.isDir && de.name == came_from {
+                    opts = append(opts, dmx.WithDefault(de))
+                    break
+                }
+            }
+            came_from = ""
+        }
+        var results []dmx.Result
+        if multiple {
+            results, err = dmx.ChooseMulti(cur_path, entriez, opts...)
+        } else {
+            var res dmx.Result
+            res, err = dmx.Choose(cur_path, entriez, opts...)
+            results = []dmx.Result{ res }
+        }
         if err != nil {
-            dbglog("Error in dmx.DmenuSelect(): %s\n", err)
+            die(err, "Error in dmx.Choose(): %s\n", err)
         }
+        if len(results) > 1 {
+            if paths := chosenPaths(cur_path, results, returnDir); len(paths) > 0 {
+                return paths
+            }
+            continue
+        }
+        res := results[0]
         
-        if dmx_output == nil {
+        if res.Cancelled {
             pel := len(pathElts)
             if pel <= 1 {
-                return ""
+                return nil
             } else {
+                came_from = pathElts[pel-1]
                 pathElts = pathElts[:pel-1]
                 continue
             }
         }
         
-        choice := dmx_output.(*DirEntry)
+        if res.Item == nil {
+            typed := res.Typed
+            if typed == "" {
+                continue
+            }
+            if !filepath.IsAbs(typed) {
+                typed = filepath.Join(cur_path, typed)
+            }
+            typed = filepath.Clean(typed)
+            fi, err := os.Stat(typed)
+            if err == nil && fi.IsDir() {
+                pathElts = parsePath(typed)
+                continue
+            }
+            remember(typed)
+            return []string{ typed }
+        }
+        
+        choice := res.Item.(*DirEntry)
         if choice == directorySelector {
-            return cur_path
+            remember(cur_path)
+            return []string{ cur_path }
         } else if choice == hiddenShower {
             showHidden = true
         } else if choice == hiddenHider {
             showHidden = false
         } else if choice.isDir {
+            remember(filepath.Join(cur_path, choice.name))
             pathElts = append(pathElts, choice.name)
         } else {
-            return filepath.Join(cur_path, choice.name)
+            remember(filepath.Join(cur_path, choice.name))
+            return []string{ filepath.Join(cur_path, choice.name) }
+        }
+    }
+}
+
+// chosenPaths() returns the paths of the entries in dir chosen all at once
+// with -m: the files, and the directories too if returnDir is set, each
+// once, in the order they were chosen. Typed text and the special entries
+// are left out.
+//
+func chosenPaths(dir string, results []dmx.Result, returnDir bool) []string {
+    paths := make([]string, 0, len(results))
+    seen := make(map[string]bool, len(results))
+    for _, res := range results {
+        de, ok := res.Item.(*DirEntry)
+        if !ok || de == directorySelector || de == hiddenShower || de == hiddenHider {
+            continue
+        }
+        if de.isDir && !returnDir {
+            continue
+        }
+        path := filepath.Join(dir, de.name)
+        if !seen[path] {
+            seen[path] = true
+            remember(path)
+            paths = append(paths, path)
         }
     }
+    return paths
 }
         
 func init() {
@@ -213,18 +384,34 @@ func main() {
     var showHidden bool = false
     var outputFormat string = "%s\n"
     var altCfg string = ""
+    var byFrecency bool = false
     var err error = nil
     
     flag.BoolVar(&selectDirectory,   "d", false, "allow Directory selection")
     flag.BoolVar(&showHidden,        "h", false, "show Hidden files by default")
-    flag.BoolVar(&caseSensitiveSort, "s", false, "case-Sensitive filename sorting")
+    flag.BoolVar(&caseSensitiveSort, "s", false, "case-Sensitive filename sorting and matching")
+    flag.BoolVar(&longListing,       "l", false, "Long listing (show sizes and modification times)")
+    flag.BoolVar(&byFrecency,        "r", false, "Rank entries by how often and how Recently they're chosen")
+    flag.BoolVar(&multiple,          "m", false, "allow choosing Multiple entries at once")
     flag.StringVar(&outputFormat,    "f", "%s\n", "output Formatting string")
     flag.StringVar(&altCfg,          "config", "", "specify alternate CONFIGuration file")
+    flag.StringVar(&dmx.Default.Theme, "theme", "", "use the named THEME from the configuration file")
     flag.Parse()
+    
+    cfg := dmx.NewLoader("fdmfc")
+    cfg.Bool(&showHidden,        "show_hidden",    "h")
+    cfg.Bool(&caseSensitiveSort, "case_sensitive", "s")
+    cfg.Bool(&longListing,       "long",           "l")
+    cfg.Bool(&byFrecency,        "frecency",       "r")
+    cfg.Bool(&multiple,          "multiple",       "m")
+    var cfg_err error
     if altCfg == "" {
-        dmx.Autoconfigure(nil)
+        cfg_err = cfg.Load(nil)
     } else {
-        dmx.Autoconfigure([]string{altCfg})
+        cfg_err = cfg.Load([]string{altCfg})
+    }
+    if cfg_err != nil {
+        fmt.Fprintf(os.Stderr, "fdmfc: %v\n", cfg_err)
     }
     dbglog("directory arg: %v\n", flag.Arg(0))
     baseDir := flag.Arg(0)
@@ -238,7 +425,24 @@ func main() {
     }
     dbglog("baseDir: %v\n", baseDir)
     
+    if byFrecency {
+        hist, err = history.Open("fdmfc")
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "fdmfc: %v\n", err)
+        }
+    }
+    
     v := selectPath(parsePath(baseDir), selectDirectory, showHidden)
+    if hist != nil && len(v) > 0 {
+        if err := hist.Save(); err != nil {
+            fmt.Fprintf(os.Stderr, "fdmfc: %v\n", err)
+        }
+    }
     
-    fmt.Printf(outputFormat, v)
+    if len(v) == 0 {
+        fmt.Printf(outputFormat, "")
+    }
+    for _, path := range v {
+        fmt.Printf(outputFormat, path)
+    }
 }
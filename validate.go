@@ -0,0 +1,170 @@
+// validate.go
+//
+// Checking dmx.conf for mistakes.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "fmt"; "os"; "os/exec"; "regexp"; "sort"; "strings"; "sync" )
+
+// A Problem is something wrong with a configuration file.
+//
+type Problem struct {
+    File string
+    Line int            // 0 if the problem isn't on any particular line
+    Msg  string
+}
+
+func (p Problem) Error() string {
+    if p.Line > 0 {
+        return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Msg)
+    }
+    return fmt.Sprintf("%s: %s", p.File, p.Msg)
+}
+
+var(
+    extraKeysMu sync.Mutex
+    extraKeys = map[string]map[string]bool{}
+)
+
+// RegisterKeys() tells ValidateConfig() about configuration keys that
+// belong to something other than dmx itself (a program using it, say), so
+// they aren't reported as unknown. section is the name of the [section]
+// they go in, or "" for keys at the top of the file.
+//
+func RegisterKeys(section string, keys ...string) {
+    extraKeysMu.Lock()
+    defer extraKeysMu.Unlock()
+    section = sectionName(section)
+    if extraKeys[section] == nil {
+        extraKeys[section] = make(map[string]bool)
+    }
+    for _, k := range keys {
+        extraKeys[section][strings.ToLower(k)] = true
+    }
+}
+
+func isRegistered(section, key string) bool {
+    extraKeysMu.Lock()
+    defer extraKeysMu.Unlock()
+    if key == "" {
+        _, ok := extraKeys[section]
+        return ok
+    }
+    return extraKeys[section][key]
+}
+
+// colorRe matches the colors dmenu understands: #rgb, #rrggbb, or an X
+// color name (which can't be checked without asking the X server).
+//
+var colorRe = regexp.MustCompile(`^(#[[:xdigit:]]{3}|#[[:xdigit:]]{6}|[[:alpha:]][[:alpha:] ]*[[:alnum:]]*)$`)
+
+// ValidateConfig() reads the configuration file fname and reports every
+// problem it can find with it: unknown sections and keys (see also
+// RegisterKeys()), malformed colors, values that aren't numbers or yes/no
+// where they should be, a THEME with no section, an unknown BACKEND, a
+// DMENU that isn't there, and a BACKEND none of whose menu programs are
+// there. The error is only for failing to read the file at all.
+//
+func ValidateConfig(fname string) ([]Problem, error) {
+    cf, err := readConfig(fname)
+    if err != nil {
+        return nil, err
+    }
+    probs := make([]Problem, 0)
+    add := func(line int, msgfmt string, args ...interface{}) {
+        probs = append(probs, Problem{ fname, line, fmt.Sprintf(msgfmt, args...) })
+    }
+    
+    known := make(map[string]bool, len(menuKeys))
+    for _, k := range menuKeys {
+        known[k] = true
+    }
+    
+    scratch := NewMenu()
+    for sect, keys := range cf.sections {
+        is_theme := strings.HasPrefix(sect, "theme ")
+        if sect != "" && !is_theme && !isRegistered(sect, "") {
+            add(cf.headers[sect], "unknown section [%s]", sect)
+            continue
+        }
+        for key, cv := range keys {
+            switch {
+                case known[key]:
+                    if _, err := scratch.set(key, cv.val); err != nil {
+                        add(cv.line, "%v", err)
+                    } else if strings.HasSuffix(key, "_bg") || strings.HasSuffix(key, "_fg") {
+                        if cv.val != "" && !colorRe.MatchString(cv.val) {
+                            add(cv.line, "malformed color %q for %s", cv.val, strings.ToUpper(key))
+                        }
+                    }
+                case key == "theme" && !is_theme:
+                    if !cf.hasSection("theme " + cv.val) {
+                        add(cv.line, "THEME %q has no [theme %s] section", cv.val, cv.val)
+                    }
+                case isRegistered(sect, key):
+                default:
+                    add(cv.line, "unknown key %s", strings.ToUpper(key))
+            }
+        }
+    }
+    
+    // Check that the menu program is there, using the settings at the top
+    // of the file.
+    m := NewMenu()
+    for key, cv := range cf.section("") {
+        m.set(key, cv.val)
+    }
+    line := cf.section("")["backend"].line
+    names := m.backendNames()
+    for _, name := range names {
+        if _, ok := Backends[name]; !ok {
+            add(line, "unknown BACKEND %q", name)
+        }
+    }
+    if cv := cf.section("")["dmenu"]; cv.line > 0 {
+        if _, err := exec.LookPath(m.DmenuPath); err != nil {
+            add(cv.line, "dmenu executable %s not found", m.DmenuPath)
+        }
+    }
+    // With a list of backends, it's fine for some of them to be missing,
+    // as long as one of them is there. BACKEND=auto only falls back on the
+    // terminal for want of all the others, which is no good from a key
+    // binding, so one of the others had better be there too.
+    candidates := make([]string, 0, len(names))
+    name := strings.ToLower(strings.TrimSpace(m.BackendName))
+    is_auto := name == "" || name == "auto"
+    for _, name := range names {
+        if Backends[name] != nil && !(is_auto && name == "tty") {
+            candidates = append(candidates, name)
+        }
+    }
+    found := len(candidates) == 0
+    for _, name := range candidates {
+        if _, ok := m.lookBackend(name); ok {
+            found = true
+            break
+        }
+    }
+    if !found && len(names) == 1 {
+        add(line, "%s executable not found", names[0])
+    } else if !found {
+        add(line, "none of the backends %s was found", strings.Join(candidates, ", "))
+    }
+    
+    sort.SliceStable(probs, func(i, j int) bool { return probs[i].Line < probs[j].Line })
+    return probs, nil
+}
+
+// FindConfig() returns the first of the files that Autoconfigure() would
+// look at (see ConfigPaths()) that exists, or "" if none of them do.
+//
+func FindConfig(other_cfgs []string) string {
+    for _, fname := range ConfigPaths(other_cfgs) {
+        if _, err := os.Stat(fname); err == nil {
+            return fname
+        }
+    }
+    return ""
+}
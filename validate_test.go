@@ -0,0 +1,105 @@
+// validate_test.go
+//
+// Tests for ValidateConfig().
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "os"; "path/filepath"; "testing" )
+
+// writeConf() writes text to a dmx.conf in a fresh temporary directory and
+// returns its path.
+//
+func writeConf(t *testing.T, text string) string {
+    t.Helper()
+    fname := filepath.Join(t.TempDir(), "dmx.conf")
+    if err := os.WriteFile(fname, []byte(text), 0644); err != nil {
+        t.Fatal(err)
+    }
+    return fname
+}
+
+func TestValidateConfig(t *testing.T) {
+    tests := []struct {
+        name  string
+        text  string
+        line  int
+        msg   string
+    }{
+        { "unknown key", "BACKEND=tty\nFONT=mono\nFROB=yes\n",
+          3, "unknown key FROB" },
+        { "bad color", "BACKEND=tty\nNORMAL_BG=#12345\n",
+          2, `malformed color "#12345" for NORMAL_BG` },
+        { "bad color in theme", "BACKEND=tty\n\n[theme dark]\nSELECTED_FG=#zzz\n",
+          4, `malformed color "#zzz" for SELECTED_FG` },
+        { "missing theme", "BACKEND=tty\nTHEME=nope\n\n[theme dark]\nNORMAL_BG=#000\n",
+          2, `THEME "nope" has no [theme nope] section` },
+        { "unknown section", "BACKEND=tty\n\n# tools\n[Nosuch  Tool]\nKEY=val\n",
+          4, "unknown section [nosuch tool]" },
+        { "unknown backend", "BACKEND=nosuch\n",
+          1, `unknown BACKEND "nosuch"` },
+        { "bad value", "BACKEND=tty\nLINES=lots\n",
+          2, "" },
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            fname := writeConf(t, tc.text)
+            probs, err := ValidateConfig(fname)
+            if err != nil {
+                t.Fatalf("ValidateConfig(): %v", err)
+            }
+            if len(probs) != 1 {
+                t.Fatalf("got %d problems, want 1: %q", len(probs), probs)
+            }
+            p := probs[0]
+            if p.File != fname || p.Line != tc.line {
+                t.Errorf("problem at %s:%d, want %s:%d", p.File, p.Line, fname, tc.line)
+            }
+            if tc.msg != "" && p.Msg != tc.msg {
+                t.Errorf("got message %q, want %q", p.Msg, tc.msg)
+            }
+        })
+    }
+}
+
+func TestValidateConfigClean(t *testing.T) {
+    RegisterKeys("validatetest", "clip_dir")
+    RegisterKeys("", "validatetest_clip_dir")
+    fname := writeConf(t, `# A file with nothing wrong with it.
+BACKEND = tty
+FONT = monospace:size=10
+NORMAL_BG = #222
+selected_fg = #ffffff
+THEME = dark
+VALIDATETEST_CLIP_DIR = /tmp
+
+[theme dark]
+NORMAL_BG = black
+
+[ValidateTest]
+CLIP_DIR = ~/clips
+theme = dark
+`)
+    probs, err := ValidateConfig(fname)
+    if err != nil {
+        t.Fatalf("ValidateConfig(): %v", err)
+    }
+    for _, p := range probs {
+        t.Errorf("unexpected problem: %v", p)
+    }
+}
+
+func TestValidateConfigMissing(t *testing.T) {
+    _, err := ValidateConfig(filepath.Join(t.TempDir(), "dmx.conf"))
+    if !os.IsNotExist(err) {
+        t.Errorf("got error %v, want one saying the file doesn't exist", err)
+    }
+}
+
+func TestProblemError(t *testing.T) {
+    p := Problem{ "dmx.conf", 7, "unknown key FROB" }
+    if got, want := p.Error(), "dmx.conf:7: unknown key FROB"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+}
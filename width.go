@@ -0,0 +1,143 @@
+// width.go
+//
+// How wide strings look on the screen.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "sort"; "strings"; "unicode" )
+
+// wideRunes lists (in order) the ranges of code points that take up two
+// columns: the East Asian Wide and Fullwidth characters, and the emoji
+// that are shown as pictures by default.
+//
+var wideRunes = [][2]rune{
+    { 0x1100, 0x115F }, { 0x231A, 0x231B }, { 0x2329, 0x232A },
+    { 0x23E9, 0x23EC }, { 0x23F0, 0x23F0 }, { 0x23F3, 0x23F3 },
+    { 0x25FD, 0x25FE }, { 0x2614, 0x2615 }, { 0x2648, 0x2653 },
+    { 0x267F, 0x267F }, { 0x2693, 0x2693 }, { 0x26A1, 0x26A1 },
+    { 0x26AA, 0x26AB }, { 0x26BD, 0x26BE }, { 0x26C4, 0x26C5 },
+    { 0x26CE, 0x26CE }, { 0x26D4, 0x26D4 }, { 0x26EA, 0x26EA },
+    { 0x26F2, 0x26F3 }, { 0x26F5, 0x26F5 }, { 0x26FA, 0x26FA },
+    { 0x26FD, 0x26FD }, { 0x2705, 0x2705 }, { 0x270A, 0x270B },
+    { 0x2728, 0x2728 }, { 0x274C, 0x274C }, { 0x274E, 0x274E },
+    { 0x2753, 0x2755 }, { 0x2757, 0x2757 }, { 0x2795, 0x2797 },
+    { 0x27B0, 0x27B0 }, { 0x27BF, 0x27BF }, { 0x2B1B, 0x2B1C },
+    { 0x2B50, 0x2B50 }, { 0x2B55, 0x2B55 }, { 0x2E80, 0x303E },
+    { 0x3041, 0x33FF }, { 0x3400, 0x4DBF }, { 0x4E00, 0x9FFF },
+    { 0xA000, 0xA4CF }, { 0xA960, 0xA97F }, { 0xAC00, 0xD7A3 },
+    { 0xF900, 0xFAFF }, { 0xFE10, 0xFE19 }, { 0xFE30, 0xFE6F },
+    { 0xFF00, 0xFF60 }, { 0xFFE0, 0xFFE6 }, { 0x16FE0, 0x16FE4 },
+    { 0x17000, 0x18AFF }, { 0x1B000, 0x1B2FF }, { 0x1F004, 0x1F004 },
+    { 0x1F0CF, 0x1F0CF }, { 0x1F18E, 0x1F18E }, { 0x1F191, 0x1F19A },
+    { 0x1F1E6, 0x1F1FF }, { 0x1F200, 0x1F251 }, { 0x1F300, 0x1F320 },
+    { 0x1F32D, 0x1F335 }, { 0x1F337, 0x1F37C }, { 0x1F37E, 0x1F393 },
+    { 0x1F3A0, 0x1F3CA }, { 0x1F3CF, 0x1F3D3 }, { 0x1F3E0, 0x1F3F0 },
+    { 0x1F3F4, 0x1F3F4 }, { 0x1F3F8, 0x1F43E }, { 0x1F440, 0x1F440 },
+    { 0x1F442, 0x1F4FC }, { 0x1F4FF, 0x1F53D }, { 0x1F54B, 0x1F54E },
+    { 0x1F550, 0x1F567 }, { 0x1F57A, 0x1F57A }, { 0x1F595, 0x1F596 },
+    { 0x1F5A4, 0x1F5A4 }, { 0x1F5FB, 0x1F64F }, { 0x1F680, 0x1F6C5 },
+    { 0x1F6CC, 0x1F6CC }, { 0x1F6D0, 0x1F6D2 }, { 0x1F6D5, 0x1F6D7 },
+    { 0x1F6EB, 0x1F6EC }, { 0x1F6F4, 0x1F6FC }, { 0x1F7E0, 0x1F7EB },
+    { 0x1F90C, 0x1F93A }, { 0x1F93C, 0x1F945 }, { 0x1F947, 0x1F9FF },
+    { 0x1FA70, 0x1FAFF }, { 0x20000, 0x2FFFD }, { 0x30000, 0x3FFFD },
+}
+
+const(
+    zeroWidthJoiner rune = 0x200D
+    firstRegional   rune = 0x1F1E6      // regional indicators (flags)
+    lastRegional    rune = 0x1F1FF
+    firstSkinTone   rune = 0x1F3FB      // emoji skin tone modifiers
+    lastSkinTone    rune = 0x1F3FF
+)
+
+func isWide(r rune) bool {
+    if r < wideRunes[0][0] {
+        return false
+    }
+    n := sort.Search(len(wideRunes), func(i int) bool { return wideRunes[i][1] >= r })
+    return n < len(wideRunes) && wideRunes[n][0] <= r
+}
+
+func isZeroWidth(r rune) bool {
+    return r < 32 || (r >= 0x7f && r < 0xa0) ||
+           unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) ||
+           (r >= 0x1160 && r <= 0x11FF)         // Hangul medial vowels and finals
+}
+
+// Width() returns the number of columns s takes up in a monospaced font.
+// East Asian wide characters and emoji count double; combining marks,
+// control and format characters (like the zero width joiner) count for
+// nothing, as does anything joined onto the previous character with a
+// zero width joiner, a skin tone modifier, or the second half of a flag.
+// (Invalid UTF-8 bytes count one column each.)
+//
+func Width(s string) int {
+    w := 0
+    var ws widthState
+    for _, r := range s {
+        n, _ := ws.step(r)
+        w += n
+    }
+    return w
+}
+
+// widthState is what Width() has to remember from one rune to the next.
+//
+type widthState struct {
+    prev   rune
+    joined bool         // the last rune was a zero width joiner
+}
+
+// step() returns the number of columns r adds, and whether it starts a new
+// character, rather than being part of (or joined onto) the one before.
+//
+func (ws *widthState) step(r rune) (int, bool) {
+    prev := ws.prev
+    ws.prev = r
+    switch {
+        case ws.joined:
+            ws.joined = false
+            return 0, false
+        case r >= 0x20 && r < 0x7f:
+            return 1, true      // plain ASCII, by far the most common
+        case r == zeroWidthJoiner:
+            ws.joined = true
+            return 0, false
+        case r >= firstSkinTone && r <= lastSkinTone && isWide(prev):
+            return 0, false     // modifies the emoji before it
+        case r >= firstRegional && r <= lastRegional &&
+             prev >= firstRegional && prev <= lastRegional:
+            ws.prev = -1        // a pair makes one flag; a third starts another
+            return 0, false
+        case isZeroWidth(r):
+            return 0, false
+        case isWide(r):
+            return 2, true
+    }
+    return 1, true
+}
+
+// Pad() returns s with enough spaces added to the end to make it width
+// columns wide (by Width()). It is the display-width-aware version of
+// fmt.Sprintf("%-*s", width, s), which counts bytes.
+//
+func Pad(s string, width int) string {
+    w := Width(s)
+    if w >= width {
+        return s
+    }
+    return s + strings.Repeat(" ", width - w)
+}
+
+// PadLeft() is like Pad(), but adds the spaces to the front, so s is
+// right-aligned.
+//
+func PadLeft(s string, width int) string {
+    w := Width(s)
+    if w >= width {
+        return s
+    }
+    return strings.Repeat(" ", width - w) + s
+}
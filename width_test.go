@@ -0,0 +1,70 @@
+// width_test.go
+//
+// Tests for measuring and padding strings by display width.
+//
+// https://github.com/d2718/dmx
+//
+package dmx
+
+import( "testing" )
+
+func TestWidth(t *testing.T) {
+    tests := []struct {
+        name string
+        s    string
+        w    int
+    }{
+        { "empty", "", 0 },
+        { "ASCII", "hello", 5 },
+        { "accented", "café", 4 },
+        { "combining mark", "cafe\u0301", 4 },
+        { "control characters", "a\tb\x1b", 2 },
+        { "CJK", "漢字", 4 },
+        { "fullwidth", "ＡＢ", 4 },
+        { "Hangul syllable", "한", 2 },
+        { "Hangul jamo", "\u1100\u1161\u11a8", 2 },
+        { "emoji", "👍", 2 },
+        { "skin tone", "👍🏽", 2 },
+        { "skin tone on text", "a🏽", 3 },
+        { "ZWJ family", "👨\u200d👩\u200d👧", 2 },
+        { "ZWJ then text", "👨\u200d👩x", 3 },
+        { "flag", "🇺🇸", 2 },
+        { "two flags", "🇺🇸🇬🇧", 4 },
+        { "odd regional indicator", "🇺🇸🇬", 4 },
+        { "zero width joiner alone", "\u200d", 0 },
+        { "invalid UTF-8", "a\xffb", 3 },
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            if w := Width(tc.s); w != tc.w {
+                t.Errorf("Width(%q) = %d, expected %d", tc.s, w, tc.w)
+            }
+        })
+    }
+}
+
+func TestPad(t *testing.T) {
+    tests := []struct {
+        s     string
+        width int
+        left  string        // Pad()
+        right string        // PadLeft()
+    }{
+        { "ab", 4, "ab  ", "  ab" },
+        { "ab", 2, "ab", "ab" },
+        { "abc", 2, "abc", "abc" },
+        { "", 3, "   ", "   " },
+        { "漢", 4, "漢  ", "  漢" },
+        { "🇺🇸", 3, "🇺🇸 ", " 🇺🇸" },
+        { "café", 5, "café ", " café" },
+        { "x", -1, "x", "x" },
+    }
+    for _, tc := range tests {
+        if got := Pad(tc.s, tc.width); got != tc.left {
+            t.Errorf("Pad(%q, %d) = %q, expected %q", tc.s, tc.width, got, tc.left)
+        }
+        if got := PadLeft(tc.s, tc.width); got != tc.right {
+            t.Errorf("PadLeft(%q, %d) = %q, expected %q", tc.s, tc.width, got, tc.right)
+        }
+    }
+}